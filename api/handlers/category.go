@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"shopifyx/api/responses"
+	"shopifyx/db/entity"
+	"shopifyx/db/functions"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/gofiber/fiber/v2"
+)
+
+var slugRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type (
+	Category struct {
+		Database     *functions.Category
+		UserDatabase *functions.User
+	}
+
+	CategoryPayload struct {
+		Name     string `json:"name"`
+		Slug     string `json:"slug"`
+		ParentId string `json:"parentId"`
+	}
+
+	CategoryResponse struct {
+		CategoryId string             `json:"categoryId"`
+		ParentId   string             `json:"parentId,omitempty"`
+		Name       string             `json:"name"`
+		Slug       string             `json:"slug"`
+		Children   []CategoryResponse `json:"children"`
+	}
+)
+
+func (app CategoryPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Name cannot be empty, and the length must be between 2 and 60.
+		validation.Field(&app.Name, validation.Required, validation.Length(2, 60)),
+		// Slug cannot be empty, and should be lowercase words joined by dashes.
+		validation.Field(&app.Slug, validation.Required, validation.Length(2, 60), validation.Match(slugRegexp)),
+		// ParentId is optional and should be numeric.
+		validation.Field(&app.ParentId, is.Digit),
+	)
+}
+
+func (app CategoryPayload) parentID() *int {
+	if app.ParentId == "" {
+		return nil
+	}
+
+	parentID, _ := strconv.Atoi(app.ParentId)
+	return &parentID
+}
+
+// convertCategoriesToTree nests the flat category list under their parents.
+func (ct *Category) convertCategoriesToTree(categories []entity.Category) []CategoryResponse {
+	children := map[int][]entity.Category{}
+	roots := []entity.Category{}
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+			continue
+		}
+		children[*category.ParentID] = append(children[*category.ParentID], category)
+	}
+
+	var build func(categories []entity.Category) []CategoryResponse
+	build = func(categories []entity.Category) []CategoryResponse {
+		result := []CategoryResponse{}
+		for _, category := range categories {
+			response := ct.convertCategoryEntityToResponse(category)
+			response.Children = build(children[category.ID])
+			result = append(result, response)
+		}
+		return result
+	}
+
+	return build(roots)
+}
+
+func (ct *Category) convertCategoryEntityToResponse(category entity.Category) CategoryResponse {
+	var parentID string
+	if category.ParentID != nil {
+		parentID = strconv.Itoa(*category.ParentID)
+	}
+
+	return CategoryResponse{
+		CategoryId: strconv.Itoa(category.ID),
+		ParentId:   parentID,
+		Name:       category.Name,
+		Slug:       category.Slug,
+		Children:   []CategoryResponse{},
+	}
+}
+
+func (ct *Category) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, functions.ErrCategorySlugDuplicate),
+		errors.Is(err, functions.ErrCategoryHasChildren),
+		errors.Is(err, functions.ErrCategoryCycle),
+		strings.Contains(err.Error(), "failed parse category id"):
+		status, response := responses.ErrorBadRequests(err.Error())
+		return c.Status(status).JSON(response)
+	case errors.Is(err, fiber.ErrUnauthorized):
+		return fiber.ErrUnauthorized
+	case errors.Is(err, fiber.ErrForbidden):
+		return fiber.ErrForbidden
+	case errors.Is(err, functions.ErrCategoryNotFound):
+		status, response := responses.ErrorNotFound(err.Error())
+		return c.Status(status).JSON(response)
+	default:
+		validationErrors, ok := err.(validation.Errors)
+		if !ok {
+			status, response := responses.ErrorServer(err.Error())
+			return c.Status(status).JSON(response)
+		}
+
+		errMessages := []string{}
+		for key, ve := range validationErrors {
+			errMessages = append(errMessages, fmt.Sprintf(
+				"field %s: %s",
+				key,
+				ve.Error()))
+		}
+
+		status, response := responses.ErrorBadRequests(strings.Join(errMessages, ""))
+		return c.Status(status).JSON(response)
+	}
+}
+
+// requireAdmin only lets users flagged as admin through.
+func (ct *Category) requireAdmin(c *fiber.Ctx) error {
+	user, err := ct.UserDatabase.GetUserById(c.UserContext(), c.Locals("user_id").(string))
+	if err != nil {
+		return fiber.ErrUnauthorized
+	}
+
+	if !user.IsAdmin {
+		return fiber.ErrForbidden
+	}
+
+	return nil
+}
+
+func (ct *Category) GetCategories(c *fiber.Ctx) error {
+	categories, err := ct.Database.FindAll(c.UserContext())
+	if err != nil {
+		return ct.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    ct.convertCategoriesToTree(categories),
+	})
+}
+
+func (ct *Category) AddCategory(c *fiber.Ctx) error {
+	if err := ct.requireAdmin(c); err != nil {
+		return ct.handleError(c, err)
+	}
+
+	var payload CategoryPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err := payload.Validate()
+	if err != nil {
+		return ct.handleError(c, err)
+	}
+
+	category, err := ct.Database.Add(c.UserContext(), entity.Category{
+		ParentID: payload.parentID(),
+		Name:     payload.Name,
+		Slug:     payload.Slug,
+	})
+	if err != nil {
+		return ct.handleError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(map[string]interface{}{
+		"message": "category created successfully",
+		"data":    ct.convertCategoryEntityToResponse(category),
+	})
+}
+
+func (ct *Category) UpdateCategory(c *fiber.Ctx) error {
+	if err := ct.requireAdmin(c); err != nil {
+		return ct.handleError(c, err)
+	}
+
+	categoryID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return ct.handleError(c, errors.New("failed parse category id"))
+	}
+
+	var payload CategoryPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return ct.handleError(c, err)
+	}
+
+	category, err := ct.Database.Update(c.UserContext(), entity.Category{
+		ID:       categoryID,
+		ParentID: payload.parentID(),
+		Name:     payload.Name,
+		Slug:     payload.Slug,
+	})
+	if err != nil {
+		return ct.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "category updated successfully",
+		"data":    ct.convertCategoryEntityToResponse(category),
+	})
+}
+
+func (ct *Category) DeleteCategory(c *fiber.Ctx) error {
+	if err := ct.requireAdmin(c); err != nil {
+		return ct.handleError(c, err)
+	}
+
+	categoryID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return ct.handleError(c, errors.New("failed parse category id"))
+	}
+
+	err = ct.Database.Delete(c.UserContext(), categoryID)
+	if err != nil {
+		return ct.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "category deleted successfully",
+	})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"shopifyx/configs"
+	"shopifyx/internal/money"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -9,4 +10,7 @@ import (
 type Dependencies struct {
 	Cfg    configs.Config
 	DbPool *pgxpool.Pool
+	// Rates converts prices between currencies, nil when no rates file is
+	// configured.
+	Rates money.Rates
 }
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopifyx/api/responses"
+	"shopifyx/db/entity"
+	"shopifyx/db/functions"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	Notification struct {
+		Database *functions.Notification
+	}
+
+	QueryFilterGetNotifications struct {
+		Limit      int  `json:"limit"`
+		Offset     int  `json:"offset"`
+		UnreadOnly bool `json:"unreadOnly"`
+	}
+
+	NotificationResponse struct {
+		NotificationId string     `json:"notificationId"`
+		Type           string     `json:"type"`
+		ProductId      string     `json:"productId,omitempty"`
+		Message        string     `json:"message"`
+		ReadAt         *time.Time `json:"readAt"`
+		CreatedAt      time.Time  `json:"createdAt"`
+	}
+)
+
+func (app QueryFilterGetNotifications) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Limit should be between 0 and 100.
+		validation.Field(&app.Limit, validation.Min(0), validation.Max(100)),
+		// Offset should be greater than 0.
+		validation.Field(&app.Offset, validation.Min(0)),
+	)
+}
+
+func (n *Notification) convertNotificationEntityToResponse(notification entity.Notification) NotificationResponse {
+	var productID string
+	if notification.ProductID != nil {
+		productID = strconv.Itoa(*notification.ProductID)
+	}
+
+	return NotificationResponse{
+		NotificationId: strconv.Itoa(notification.ID),
+		Type:           notification.Type,
+		ProductId:      productID,
+		Message:        notification.Message,
+		ReadAt:         notification.ReadAt,
+		CreatedAt:      notification.CreatedAt,
+	}
+}
+
+func (n *Notification) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case strings.Contains(err.Error(), "failed parse payload"),
+		strings.Contains(err.Error(), "failed parse notification id"):
+		status, response := responses.ErrorBadRequests(err.Error())
+		return c.Status(status).JSON(response)
+	case errors.Is(err, functions.ErrNoRow):
+		status, response := responses.ErrorNotFound("no notification found")
+		return c.Status(status).JSON(response)
+	default:
+		validationErrors, ok := err.(validation.Errors)
+		if !ok {
+			status, response := responses.ErrorServer(err.Error())
+			return c.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorBadRequests(validationMessage(validationErrors))
+		return c.Status(status).JSON(response)
+	}
+}
+
+func (n *Notification) GetNotifications(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return n.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	var filter QueryFilterGetNotifications
+	if err := c.QueryParser(&filter); err != nil {
+		return n.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return n.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	notifications, total, err := n.Database.FindByUserID(c.UserContext(), userID, filter.UnreadOnly, filter.Limit, filter.Offset)
+	if err != nil {
+		return n.handleError(c, err)
+	}
+
+	result := []NotificationResponse{}
+	for _, notification := range notifications {
+		result = append(result, n.convertNotificationEntityToResponse(notification))
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    result,
+		"meta": Meta{
+			Limit:  filter.Limit,
+			Offset: filter.Offset,
+			Total:  total,
+		},
+	})
+}
+
+func (n *Notification) MarkNotificationRead(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return n.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	notificationID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return n.handleError(c, errors.New("failed parse notification id"))
+	}
+
+	err = n.Database.MarkRead(c.UserContext(), notificationID, userID)
+	if err != nil {
+		return n.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "notification marked as read",
+	})
+}
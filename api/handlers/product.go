@@ -1,36 +1,80 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"regexp"
 	"shopifyx/api/responses"
 	"shopifyx/db/entity"
 	"shopifyx/db/functions"
+	"shopifyx/internal/money"
+	"shopifyx/internal/tags"
+	"shopifyx/internal/utils"
 	"strconv"
 	"strings"
+	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/go-ozzo/ozzo-validation/is"
 	"github.com/gofiber/fiber/v2"
 )
 
+// currencyRegexp matches ISO 4217 currency codes.
+var currencyRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+
 type (
 	Product struct {
-		Database     *functions.Product
-		UserDatabase *functions.User
-		BankDatabase *functions.Bank
+		Database            *functions.Product
+		VariantDatabase     *functions.ProductVariant
+		ImageDatabase       *functions.ProductImage
+		HistoryDatabase     *functions.ProductRevision
+		ReservationDatabase *functions.StockReservation
+		MovementDatabase    *functions.StockMovement
+		ReviewDatabase      *functions.ProductReview
+		QuestionDatabase    *functions.ProductQuestion
+		WishlistDatabase    *functions.Wishlist
+		UserDatabase        *functions.User
+		BankDatabase        *functions.Bank
+		// Retention is how long deleted products can still be restored.
+		Retention time.Duration
+		// ReservationTTL is how long a stock reservation holds stock.
+		ReservationTTL time.Duration
+		// ReservationMaxQty is the quantity of a product a buyer can hold.
+		ReservationMaxQty int
+		// TagAliases normalizes the tags sellers type and buyers filter on.
+		TagAliases tags.Aliases
+		// Rates converts prices for display, nil when no rates are configured.
+		Rates money.Rates
 	}
 
 	ProductPayload struct {
 		Name           string   `json:"name"`
-		Price          int      `json:"price"`
+		Price          int64    `json:"price"`
 		ImageURL       string   `json:"imageUrl"`
 		Stock          int      `json:"stock"`
 		Condition      string   `json:"condition"`
 		Tags           []string `json:"tags"`
 		IsPurchaseable bool     `json:"isPurchaseable"`
+		CategoryId     string   `json:"categoryId"`
+		// Status defaults to published when a product is created, and is left
+		// unchanged by an update when empty.
+		Status    string     `json:"status"`
+		PublishAt *time.Time `json:"publishAt"`
+		// LowStockThreshold is the stock at which the seller is notified, 0
+		// disables the notification.
+		LowStockThreshold int `json:"lowStockThreshold"`
+		// SalePrice replaces Price until SaleEndsAt, from SaleStartsAt when
+		// given.
+		SalePrice    *int64     `json:"salePrice"`
+		SaleStartsAt *time.Time `json:"saleStartsAt"`
+		SaleEndsAt   *time.Time `json:"saleEndsAt"`
+		// Currency of the prices, IDR when a product is created without one
+		// and left unchanged by an update when empty.
+		Currency string `json:"currency"`
 	}
 
 	QueryFilterGetProducts struct {
@@ -45,24 +89,54 @@ type (
 		SortBy         string   `json:"sortBy"`
 		OrderBy        string   `json:"orderBy"`
 		Search         string   `json:"search"`
+		Category       string   `json:"category"`
+		Cursor         string   `json:"cursor"`
+		Facets         bool     `json:"facets"`
+		LowStock       bool     `json:"lowStock"`
+		// Currency adds the prices converted to it for display.
+		Currency string `json:"currency"`
+		// PriceCurrency restricts the listing to products priced in it. Price
+		// filters, the price sort and the facets compare amounts of a single
+		// currency, money.DefaultCurrency when none is given.
+		PriceCurrency string `json:"priceCurrency"`
 	}
 
 	ProductResponse struct {
-		ProductId      string   `json:"productId"`
-		Name           string   `json:"name"`
-		Price          int      `json:"price"`
-		ImageUrl       string   `json:"imageUrl"`
-		Stock          int      `json:"stock"`
-		Condition      string   `json:"condition"`
-		Tags           []string `json:"tags"`
-		IsPurchaseable bool     `json:"isPurchaseable"`
-		PurchaseCount  int      `json:"purchaseCount"`
+		ProductId         string                   `json:"productId"`
+		Name              string                   `json:"name"`
+		Price             int64                    `json:"price"`
+		Currency          string                   `json:"currency"`
+		EffectivePrice    int64                    `json:"effectivePrice"`
+		DisplayPrice      *money.Money             `json:"displayPrice,omitempty"`
+		SalePrice         *int64                   `json:"salePrice,omitempty"`
+		SaleStartsAt      *time.Time               `json:"saleStartsAt,omitempty"`
+		SaleEndsAt        *time.Time               `json:"saleEndsAt,omitempty"`
+		ImageUrl          string                   `json:"imageUrl"`
+		Stock             int                      `json:"stock"`
+		HeldStock         int                      `json:"heldStock,omitempty"`
+		Condition         string                   `json:"condition"`
+		Tags              []string                 `json:"tags"`
+		IsPurchaseable    bool                     `json:"isPurchaseable"`
+		PurchaseCount     int                      `json:"purchaseCount"`
+		CategoryId        string                   `json:"categoryId,omitempty"`
+		Status            string                   `json:"status"`
+		PublishAt         *time.Time               `json:"publishAt,omitempty"`
+		LowStockThreshold int                      `json:"lowStockThreshold,omitempty"`
+		Rating            float32                  `json:"rating"`
+		ReviewCount       int                      `json:"reviewCount"`
+		Highlight         string                   `json:"highlight,omitempty"`
+		InWishlist        *bool                    `json:"inWishlist,omitempty"`
+		Variants          []ProductVariantResponse `json:"variants,omitempty"`
+		Images            []ProductImageResponse   `json:"images,omitempty"`
+		DeletedAt         *time.Time               `json:"deletedAt,omitempty"`
 	}
 
 	Meta struct {
-		Limit  int `json:"limit"`
-		Offset int `json:"offset"`
-		Total  int `json:"total"`
+		Limit      int    `json:"limit"`
+		Offset     int    `json:"offset"`
+		Total      int    `json:"total"`
+		NextCursor string `json:"nextCursor,omitempty"`
+		PrevCursor string `json:"prevCursor,omitempty"`
 	}
 
 	Bank struct {
@@ -79,17 +153,34 @@ type (
 	}
 
 	GetProductsResponse struct {
-		Data []ProductResponse `json:"data"`
-		Meta Meta              `json:"meta"`
+		Data   []ProductResponse     `json:"data"`
+		Meta   Meta                  `json:"meta"`
+		Facets *entity.ProductFacets `json:"facets,omitempty"`
 	}
 
 	GetProductDetailResponse struct {
-		Product    ProductResponse `json:"product"`
-		SellerData SellerData      `json:"seller"`
+		Product    ProductResponse           `json:"product"`
+		SellerData SellerData                `json:"seller"`
+		Questions  []ProductQuestionResponse `json:"questions"`
 	}
 )
 
 func (app ProductPayload) Validate() error {
+	publishAtRules := []validation.Rule{}
+	if app.Status == entity.ProductStatusScheduled {
+		publishAtRules = append(publishAtRules, validation.Required)
+	}
+
+	salePriceRules := []validation.Rule{validation.Min(0)}
+	saleEndsAtRules := []validation.Rule{}
+	if app.SalePrice != nil {
+		salePriceRules = append(salePriceRules, validation.Max(app.Price).Exclusive())
+		saleEndsAtRules = append(saleEndsAtRules, validation.Required)
+	}
+	if app.SaleStartsAt != nil {
+		saleEndsAtRules = append(saleEndsAtRules, validation.Min(*app.SaleStartsAt).Exclusive())
+	}
+
 	return validation.ValidateStruct(&app,
 		// Name cannot be empty, and the length must be between 5 and 60.
 		validation.Field(&app.Name, validation.Required, validation.Length(5, 60)),
@@ -105,6 +196,22 @@ func (app ProductPayload) Validate() error {
 		validation.Field(&app.Tags, validation.Required),
 		// IsPurchaseable cannot be empty.
 		validation.Field(&app.IsPurchaseable, validation.NotNil),
+		// CategoryId is optional and should be numeric.
+		validation.Field(&app.CategoryId, is.Digit),
+		// Status is optional and should be either "draft", "scheduled", "published" or "archived".
+		validation.Field(&app.Status, validation.In(
+			entity.ProductStatusDraft, entity.ProductStatusScheduled, entity.ProductStatusPublished, entity.ProductStatusArchived,
+		)),
+		// PublishAt is required when the status is "scheduled".
+		validation.Field(&app.PublishAt, publishAtRules...),
+		// LowStockThreshold should be greater than or equal to 0.
+		validation.Field(&app.LowStockThreshold, validation.Min(0)),
+		// SalePrice is optional, and should be lower than Price.
+		validation.Field(&app.SalePrice, salePriceRules...),
+		// SaleEndsAt is required with a SalePrice, and should be after SaleStartsAt.
+		validation.Field(&app.SaleEndsAt, saleEndsAtRules...),
+		// Currency is optional, and should be an ISO 4217 code.
+		validation.Field(&app.Currency, validation.Match(currencyRegexp)),
 	)
 }
 
@@ -120,24 +227,133 @@ func (app QueryFilterGetProducts) Validate() error {
 		validation.Field(&app.MaxPrice, validation.Min(0)),
 		// MinPrice should be greater than 0.
 		validation.Field(&app.MinPrice, validation.Min(0)),
-		// SortBy should be either "price" or "date".
-		validation.Field(&app.SortBy, validation.In("price", "date")),
+		// SortBy should be either "price", "date", "rating" or "relevance".
+		validation.Field(&app.SortBy, validation.In("price", "date", "rating", "relevance")),
 		// OrderBy should be either "asc" or "dsc".
 		validation.Field(&app.OrderBy, validation.In("asc", "dsc")),
+		// Currency is optional, and should be an ISO 4217 code.
+		validation.Field(&app.Currency, validation.Match(currencyRegexp)),
+		// PriceCurrency is optional, and should be an ISO 4217 code.
+		validation.Field(&app.PriceCurrency, validation.Match(currencyRegexp)),
 	)
 }
 
+// categoryID converts the optional categoryId of the payload, the payload must
+// have been validated.
+func (app ProductPayload) categoryID() *int {
+	if app.CategoryId == "" {
+		return nil
+	}
+
+	categoryID, _ := strconv.Atoi(app.CategoryId)
+	return &categoryID
+}
+
 func (p *Product) convertProductEntityToResponse(product entity.Product) ProductResponse {
+	var categoryID string
+	if product.CategoryID != nil {
+		categoryID = strconv.Itoa(*product.CategoryID)
+	}
+
 	return ProductResponse{
-		ProductId:      strconv.Itoa(product.ID),
-		Name:           product.Name,
-		Price:          product.Price,
-		ImageUrl:       product.ImageUrl,
-		Stock:          product.Stock,
-		Condition:      product.Condition,
-		Tags:           product.Tags,
-		IsPurchaseable: product.IsPurchaseable,
-		PurchaseCount:  product.PurchaseCount,
+		ProductId:         strconv.Itoa(product.ID),
+		Name:              product.Name,
+		Price:             product.Price.Amount,
+		Currency:          product.Price.Currency,
+		EffectivePrice:    product.EffectivePrice.Amount,
+		SalePrice:         product.SalePrice,
+		SaleStartsAt:      product.SaleStartsAt,
+		SaleEndsAt:        product.SaleEndsAt,
+		ImageUrl:          product.ImageUrl,
+		Stock:             availableStock(product.Stock, product.HeldStock),
+		HeldStock:         product.HeldStock,
+		Condition:         product.Condition,
+		Tags:              product.Tags,
+		IsPurchaseable:    product.IsPurchaseable,
+		PurchaseCount:     product.PurchaseCount,
+		CategoryId:        categoryID,
+		Status:            product.Status,
+		PublishAt:         product.PublishAt,
+		LowStockThreshold: product.LowStockThreshold,
+		Rating:            product.Rating,
+		ReviewCount:       product.ReviewCount,
+		Highlight:         product.Highlight,
+		DeletedAt:         product.DeletedAt,
+	}
+}
+
+// convertDisplayPrices adds the effective prices converted to currency, when
+// one is asked for.
+func (p *Product) convertDisplayPrices(c *fiber.Ctx, currency string, products []ProductResponse) error {
+	if currency == "" {
+		return nil
+	}
+
+	if !currencyRegexp.MatchString(currency) {
+		return errors.New("failed parse payload: currency should be an ISO 4217 code")
+	}
+
+	if p.Rates == nil {
+		return errors.New("failed parse payload: currency conversion is not available")
+	}
+
+	for i := range products {
+		displayPrice, err := money.Convert(c.UserContext(), p.Rates, money.New(products[i].EffectivePrice, products[i].Currency), currency)
+		if err != nil {
+			if errors.Is(err, money.ErrUnknownRate) {
+				return errors.New(fmt.Sprintf("failed parse payload: %v", err.Error()))
+			}
+			return err
+		}
+		products[i].DisplayPrice = &displayPrice
+	}
+
+	return nil
+}
+
+// productETag is the entity tag of the current version of a product.
+func productETag(product entity.Product) string {
+	return `"` + strconv.Itoa(product.Version) + `"`
+}
+
+// ifMatchVersion returns the product version required by the If-Match header,
+// 0 when the header is missing or "*".
+func ifMatchVersion(c *fiber.Ctx) (int, error) {
+	ifMatch := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`))
+	if err != nil || version <= 0 {
+		return 0, functions.ErrVersionMismatch
+	}
+
+	return version, nil
+}
+
+func (p *Product) convertProductEntityToPayload(product entity.Product) ProductPayload {
+	var categoryID string
+	if product.CategoryID != nil {
+		categoryID = strconv.Itoa(*product.CategoryID)
+	}
+
+	return ProductPayload{
+		Name:              product.Name,
+		Price:             product.Price.Amount,
+		ImageURL:          product.ImageUrl,
+		Stock:             product.Stock,
+		Condition:         product.Condition,
+		Tags:              product.Tags,
+		IsPurchaseable:    product.IsPurchaseable,
+		CategoryId:        categoryID,
+		Status:            product.Status,
+		PublishAt:         product.PublishAt,
+		LowStockThreshold: product.LowStockThreshold,
+		SalePrice:         product.SalePrice,
+		SaleStartsAt:      product.SaleStartsAt,
+		SaleEndsAt:        product.SaleEndsAt,
+		Currency:          product.Price.Currency,
 	}
 }
 
@@ -146,7 +362,7 @@ func (p *Product) convertQueryFilterToEntity(filter QueryFilterGetProducts) enti
 		UserOnly:       filter.UserOnly,
 		Limit:          filter.Limit,
 		Offset:         filter.Offset,
-		Tags:           filter.Tags,
+		Tags:           p.TagAliases.Normalize(filter.Tags),
 		Condition:      filter.Condition,
 		ShowEmptyStock: filter.ShowEmptyStock,
 		MaxPrice:       filter.MaxPrice,
@@ -154,13 +370,13 @@ func (p *Product) convertQueryFilterToEntity(filter QueryFilterGetProducts) enti
 		SortBy:         filter.SortBy,
 		OrderBy:        filter.OrderBy,
 		Search:         filter.Search,
+		Category:       filter.Category,
+		LowStock:       filter.LowStock,
+		PriceCurrency:  filter.PriceCurrency,
 	}
 }
 
-func (p *Product) convertProductsToGetProductsResponse(
-	products []entity.Product,
-	limit, offset, total int,
-) GetProductsResponse {
+func (p *Product) convertProductsToGetProductsResponse(products []entity.Product, meta Meta) GetProductsResponse {
 	var result []ProductResponse
 	for _, product := range products {
 		result = append(result, p.convertProductEntityToResponse(product))
@@ -168,16 +384,80 @@ func (p *Product) convertProductsToGetProductsResponse(
 
 	return GetProductsResponse{
 		Data: result,
-		Meta: Meta{
-			Limit:  limit,
-			Offset: offset,
-			Total:  total,
-		},
+		Meta: meta,
 	}
 }
 
+func encodeProductCursor(cursor entity.ProductCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeProductCursor(s string) (*entity.ProductCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, functions.ErrInvalidCursor
+	}
+
+	var cursor entity.ProductCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, functions.ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// paginateProducts trims the extra row fetched to detect further pages and
+// builds the page meta. products must have been fetched with filter.Limit+1.
+func (p *Product) paginateProducts(products []entity.Product, filter entity.FilterGetProducts, total int) ([]entity.Product, Meta) {
+	meta := Meta{
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+		Total:  total,
+	}
+
+	if filter.Limit == 0 {
+		return products, meta
+	}
+
+	backward := filter.Cursor != nil && filter.Cursor.Backward
+	hasMore := len(products) > filter.Limit
+	if hasMore {
+		if backward {
+			products = products[1:]
+		} else {
+			products = products[:filter.Limit]
+		}
+	}
+
+	if len(products) == 0 {
+		return products, meta
+	}
+
+	hasNext, hasPrev := hasMore, filter.Cursor != nil || filter.Offset > 0
+	if backward {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	if filter.Cursor != nil {
+		meta.Offset = 0
+	}
+
+	if hasNext {
+		meta.NextCursor = encodeProductCursor(functions.NewProductCursor(filter, products[len(products)-1], false))
+	}
+
+	if hasPrev {
+		meta.PrevCursor = encodeProductCursor(functions.NewProductCursor(filter, products[0], true))
+	}
+
+	return products, meta
+}
+
 func (p *Product) convertProductToProductDetailResponse(
 	product entity.Product,
+	variants []entity.ProductVariant,
+	images []entity.ProductImage,
 	seller entity.User,
 	productSoldTotal int,
 	bankAccounts []entity.Bank,
@@ -192,8 +472,12 @@ func (p *Product) convertProductToProductDetailResponse(
 		})
 	}
 
+	productResponse := p.convertProductEntityToResponse(product)
+	productResponse.Variants = p.convertVariantsToResponse(variants)
+	productResponse.Images = p.convertImagesToResponse(images)
+
 	return GetProductDetailResponse{
-		Product: p.convertProductEntityToResponse(product),
+		Product: productResponse,
 		SellerData: SellerData{
 			Name:             seller.Name,
 			ProductSoldTotal: productSoldTotal,
@@ -205,8 +489,25 @@ func (p *Product) convertProductToProductDetailResponse(
 func (p *Product) handleError(c *fiber.Ctx, err error) error {
 	switch {
 	case errors.Is(err, functions.ErrProductNameDuplicate),
+		errors.Is(err, functions.ErrInvalidSort),
+		errors.Is(err, functions.ErrInvalidCursor),
+		errors.Is(err, functions.ErrVariantRequired),
+		errors.Is(err, functions.ErrVariantSKUDuplicate),
 		strings.Contains(err.Error(), "failed parse payload"),
-		strings.Contains(err.Error(), "failed parse product id"):
+		strings.Contains(err.Error(), "failed parse product id"),
+		errors.Is(err, functions.ErrLastProductImage),
+		errors.Is(err, functions.ErrInvalidImageOrder),
+		errors.Is(err, functions.ErrCategoryNotFound),
+		errors.Is(err, functions.ErrInsuficientQty),
+		errors.Is(err, functions.ErrNegativeStock),
+		errors.Is(err, functions.ErrReservationLimit),
+		errors.Is(err, functions.ErrNotPurchaseable),
+		errors.Is(err, functions.ErrReviewDuplicate),
+		strings.Contains(err.Error(), "failed parse review id"),
+		strings.Contains(err.Error(), "failed parse question id"),
+		strings.Contains(err.Error(), "failed parse reservation id"),
+		strings.Contains(err.Error(), "failed parse variant id"),
+		strings.Contains(err.Error(), "failed parse image id"):
 		status, response := responses.ErrorBadRequests(err.Error())
 		return c.Status(status).JSON(response)
 	case errors.Is(err, fiber.ErrUnauthorized):
@@ -216,6 +517,18 @@ func (p *Product) handleError(c *fiber.Ctx, err error) error {
 	case errors.Is(err, functions.ErrNoRow):
 		status, response := responses.ErrorNotFound("no product found")
 		return c.Status(status).JSON(response)
+	case errors.Is(err, functions.ErrNotVerifiedBuyer),
+		errors.Is(err, functions.ErrReserveOwnProduct):
+		status, response := responses.ErrorPermission(err.Error())
+		return c.Status(status).JSON(response)
+	case errors.Is(err, functions.ErrReservationNotFound),
+		errors.Is(err, functions.ErrReviewNotFound),
+		errors.Is(err, functions.ErrQuestionNotFound):
+		status, response := responses.ErrorNotFound(err.Error())
+		return c.Status(status).JSON(response)
+	case errors.Is(err, functions.ErrVersionMismatch):
+		status, response := responses.ErrorPreconditionFailed(err.Error())
+		return c.Status(status).JSON(response)
 	default:
 		validationErrors, ok := err.(validation.Errors)
 		if !ok {
@@ -252,6 +565,10 @@ func (p *Product) GetProducts(c *fiber.Ctx) error {
 		return p.handleError(c, err)
 	}
 
+	if filter.PriceCurrency == "" && (filter.MaxPrice > 0 || filter.MinPrice > 0 || filter.SortBy == "price" || filter.Facets) {
+		filter.PriceCurrency = money.DefaultCurrency
+	}
+
 	if c.Locals("user_id") != nil {
 		userIDClaim := c.Locals("user_id").(string)
 		userID, err = strconv.Atoi(userIDClaim)
@@ -261,7 +578,20 @@ func (p *Product) GetProducts(c *fiber.Ctx) error {
 	}
 
 	filterDB := p.convertQueryFilterToEntity(filter)
-	products, err := p.Database.FindAll(c.UserContext(), filterDB, userID)
+	if filter.Cursor != "" {
+		filterDB.Cursor, err = decodeProductCursor(filter.Cursor)
+		if err != nil {
+			return p.handleError(c, err)
+		}
+	}
+
+	// fetch one extra row to know whether another page exists
+	pageFilter := filterDB
+	if pageFilter.Limit > 0 {
+		pageFilter.Limit++
+	}
+
+	products, err := p.Database.FindAll(c.UserContext(), pageFilter, userID)
 	if err != nil {
 		return p.handleError(c, err)
 	}
@@ -271,7 +601,30 @@ func (p *Product) GetProducts(c *fiber.Ctx) error {
 		return p.handleError(c, err)
 	}
 
-	result := p.convertProductsToGetProductsResponse(products, filter.Limit, filter.Offset, total)
+	products, meta := p.paginateProducts(products, filterDB, total)
+	result := p.convertProductsToGetProductsResponse(products, meta)
+
+	err = p.convertDisplayPrices(c, filter.Currency, result.Data)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	// the wishlist flag is only known for a signed in caller
+	if userID != 0 && len(result.Data) > 0 {
+		err = p.markWishlisted(c, userID, result.Data)
+		if err != nil {
+			return p.handleError(c, err)
+		}
+	}
+
+	// facets are opt-in as they cost three extra aggregate queries
+	if filter.Facets {
+		facets, err := p.Database.Facets(c.UserContext(), filterDB, userID)
+		if err != nil {
+			return p.handleError(c, err)
+		}
+		result.Facets = &facets
+	}
 
 	return c.Status(http.StatusOK).JSON(map[string]interface{}{
 		"message": "ok",
@@ -280,12 +633,17 @@ func (p *Product) GetProducts(c *fiber.Ctx) error {
 }
 
 func (p *Product) GetProductDetail(c *fiber.Ctx) error {
-	productID, err := strconv.Atoi(c.Params("id"))
+	product, err := p.findVisibleProduct(c)
 	if err != nil {
-		return p.handleError(c, errors.New("failed parse product id"))
+		return p.handleError(c, err)
 	}
 
-	product, err := p.Database.FindByID(c.UserContext(), productID)
+	variants, err := p.VariantDatabase.FindByProductID(c.UserContext(), product.ID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	images, err := p.ImageDatabase.FindByProductID(c.UserContext(), product.ID)
 	if err != nil {
 		return p.handleError(c, err)
 	}
@@ -305,7 +663,22 @@ func (p *Product) GetProductDetail(c *fiber.Ctx) error {
 		return p.handleError(c, err)
 	}
 
-	result := p.convertProductToProductDetailResponse(product, user, productSoldTotal, bankAccounts)
+	questions, _, err := p.QuestionDatabase.FindByProductID(c.UserContext(), product.ID, true, detailQuestionLimit, 0)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	result := p.convertProductToProductDetailResponse(product, variants, images, user, productSoldTotal, bankAccounts)
+	result.Questions = p.convertQuestionsToResponse(questions)
+
+	displayed := []ProductResponse{result.Product}
+	err = p.convertDisplayPrices(c, c.Query("currency"), displayed)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+	result.Product = displayed[0]
+
+	c.Set(fiber.HeaderETag, productETag(product))
 
 	return c.Status(http.StatusOK).JSON(map[string]interface{}{
 		"message": "ok",
@@ -318,6 +691,9 @@ func (p *Product) BuyProduct(c *fiber.Ctx) error {
 		BankAccountId        string `json:"bankAccountId"`
 		PaymentProofImageUrl string `json:"paymentProofImageUrl"`
 		Qty                  int    `json:"quantity"`
+		VariantId            string `json:"variantId"`
+		ReservationId        string `json:"reservationId"`
+		VoucherCode          string `json:"voucherCode"`
 	}
 
 	if err := c.BodyParser(&payload); err != nil {
@@ -350,7 +726,8 @@ func (p *Product) BuyProduct(c *fiber.Ctx) error {
 			JSON("payment proof image url is empty or malformat")
 	}
 
-	if payload.Qty < 1 {
+	// the quantity of a reservation is used when none is given
+	if payload.Qty < 1 && payload.ReservationId == "" {
 		return c.
 			Status(http.StatusBadRequest).
 			JSON("minimum amount of quantity must be 1")
@@ -363,17 +740,49 @@ func (p *Product) BuyProduct(c *fiber.Ctx) error {
 			JSON("failed parse bankAccountId")
 	}
 
+	buyerID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return c.
+			Status(http.StatusUnauthorized).
+			JSON("failed parse user id")
+	}
+
+	var variantID int
+	if payload.VariantId != "" {
+		variantID, err = strconv.Atoi(payload.VariantId)
+		if err != nil {
+			return c.
+				Status(http.StatusBadRequest).
+				JSON("failed parse variantId")
+		}
+	}
+
+	var reservationID int
+	if payload.ReservationId != "" {
+		reservationID, err = strconv.Atoi(payload.ReservationId)
+		if err != nil {
+			return c.
+				Status(http.StatusBadRequest).
+				JSON("failed parse reservationId")
+		}
+	}
+
 	payment, err := p.Database.Buy(c.UserContext(), entity.Payment{
 		ProductId:            productID,
+		VariantId:            variantID,
+		ReservationId:        reservationID,
+		BuyerId:              buyerID,
 		BankAccountId:        bankAccountId,
 		PaymentProofImageUrl: payload.PaymentProofImageUrl,
 		Qty:                  payload.Qty,
+		VoucherCode:          strings.TrimSpace(payload.VoucherCode),
 	})
 
 	if err != nil {
-		if errors.Is(err, functions.ErrNoRow) {
+		if errors.Is(err, functions.ErrNoRow) || errors.Is(err, functions.ErrReservationNotFound) || errors.Is(err, functions.ErrVoucherNotFound) {
 			return c.Status(http.StatusNotFound).JSON(err.Error())
-		} else if errors.Is(err, functions.ErrInsuficientQty) {
+		} else if errors.Is(err, functions.ErrInsuficientQty) || errors.Is(err, functions.ErrVariantRequired) || errors.Is(err, functions.ErrReservationMismatch) ||
+			errors.Is(err, functions.ErrVoucherInactive) || errors.Is(err, functions.ErrVoucherNotApplicable) || errors.Is(err, functions.ErrVoucherMinSpend) || errors.Is(err, functions.ErrVoucherUsedUp) || errors.Is(err, money.ErrOverflow) {
 			return c.Status(http.StatusBadRequest).JSON(err.Error())
 		}
 
@@ -404,20 +813,29 @@ func (p *Product) AddProduct(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusBadRequest)
 	}
 
+	payload.Tags = p.TagAliases.Normalize(payload.Tags)
+
 	err = payload.Validate()
 	if err != nil {
 		return p.handleError(c, err)
 	}
 
 	product, err := p.Database.Add(c.UserContext(), entity.Product{
-		UserID:         userID,
-		Name:           payload.Name,
-		Price:          payload.Price,
-		ImageUrl:       payload.ImageURL,
-		Stock:          payload.Stock,
-		Condition:      payload.Condition,
-		Tags:           payload.Tags,
-		IsPurchaseable: payload.IsPurchaseable,
+		UserID:            userID,
+		Name:              payload.Name,
+		Price:             money.New(payload.Price, payload.Currency),
+		ImageUrl:          payload.ImageURL,
+		Stock:             payload.Stock,
+		Condition:         payload.Condition,
+		Tags:              payload.Tags,
+		IsPurchaseable:    payload.IsPurchaseable,
+		CategoryID:        payload.categoryID(),
+		Status:            payload.Status,
+		PublishAt:         payload.PublishAt,
+		LowStockThreshold: payload.LowStockThreshold,
+		SalePrice:         payload.SalePrice,
+		SaleStartsAt:      payload.SaleStartsAt,
+		SaleEndsAt:        payload.SaleEndsAt,
 	})
 
 	if err != nil {
@@ -444,19 +862,14 @@ func (p *Product) UpdateProduct(c *fiber.Ctx) error {
 		return p.handleError(c, fiber.ErrUnauthorized)
 	}
 
-	var payload ProductPayload
-	if err := c.BodyParser(&payload); err != nil {
-		return c.SendStatus(http.StatusBadRequest)
-	}
-
-	err = payload.Validate()
+	productID, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		return p.handleError(c, err)
+		return p.handleError(c, errors.New("failed parse product id"))
 	}
 
-	productID, err := strconv.Atoi(c.Params("id"))
+	version, err := ifMatchVersion(c)
 	if err != nil {
-		return p.handleError(c, errors.New("failed parse product id"))
+		return p.handleError(c, err)
 	}
 
 	product, err := p.Database.FindByIDUser(c.UserContext(), productID, userID)
@@ -467,21 +880,60 @@ func (p *Product) UpdateProduct(c *fiber.Ctx) error {
 		return p.handleError(c, err)
 	}
 
+	if version != 0 && version != product.Version {
+		return p.handleError(c, functions.ErrVersionMismatch)
+	}
+
+	// the body is a json merge patch applied to the current product, only the
+	// supplied fields change and the merged result is validated
+	current, err := json.Marshal(p.convertProductEntityToPayload(product))
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	merged, err := utils.MergePatch(current, c.Body())
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	var payload ProductPayload
+	if err := json.Unmarshal(merged, &payload); err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	payload.Tags = p.TagAliases.Normalize(payload.Tags)
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
 	product.Name = payload.Name
-	product.Price = payload.Price
+	product.Price = money.New(payload.Price, payload.Currency)
 	product.ImageUrl = payload.ImageURL
 	product.Stock = payload.Stock
 	product.Condition = payload.Condition
 	product.Tags = payload.Tags
 	product.IsPurchaseable = payload.IsPurchaseable
-
-	err = p.Database.Update(c.UserContext(), product)
+	product.CategoryID = payload.categoryID()
+	product.Status = payload.Status
+	product.PublishAt = payload.PublishAt
+	product.LowStockThreshold = payload.LowStockThreshold
+	product.SalePrice = payload.SalePrice
+	product.SaleStartsAt = payload.SaleStartsAt
+	product.SaleEndsAt = payload.SaleEndsAt
+
+	// the update is based on the version read above, a concurrent change
+	// makes it fail instead of being overwritten
+	product, err = p.Database.Update(c.UserContext(), product)
 	if err != nil {
 		return p.handleError(c, err)
 	}
 
 	result := p.convertProductEntityToResponse(product)
 
+	c.Set(fiber.HeaderETag, productETag(product))
+
 	return c.Status(http.StatusOK).JSON(map[string]interface{}{
 		"message": "product updated successfully",
 		"data":    result,
@@ -496,7 +948,8 @@ func (p *Product) UpdateStock(c *fiber.Ctx) error {
 	}
 
 	var requestBody struct {
-		Stock int `json:"stock"`
+		Stock     int    `json:"stock"`
+		VariantId string `json:"variantId"`
 	}
 	if err := c.BodyParser(&requestBody); err != nil {
 		return c.Status(http.StatusBadRequest).SendString("Invalid request body")
@@ -513,18 +966,53 @@ func (p *Product) UpdateStock(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).SendString("Invalid request body")
 	}
 
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return c.Status(http.StatusPreconditionFailed).SendString(err.Error())
+	}
+
 	productCheck, err := p.Database.FindByIDUser(c.UserContext(), productID, userID)
 
 	if err != nil {
 		return c.Status(http.StatusNotFound).SendString("Product not found")
 	}
 
+	if requestBody.VariantId != "" {
+		variantID, err := strconv.Atoi(requestBody.VariantId)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).SendString("Invalid variant id")
+		}
+
+		variant, err := p.VariantDatabase.UpdateStock(c.UserContext(), userID, productCheck.ID, variantID, requestBody.Stock, version)
+		if err != nil {
+			if errors.Is(err, functions.ErrNoRow) {
+				return c.Status(http.StatusNotFound).SendString(err.Error())
+			}
+			if errors.Is(err, functions.ErrVersionMismatch) {
+				return c.Status(http.StatusPreconditionFailed).SendString(err.Error())
+			}
+			return c.Status(http.StatusInternalServerError).SendString(err.Error())
+		}
+
+		return c.Status(http.StatusOK).JSON(map[string]interface{}{
+			"message": "stock updated successfully",
+			"data":    p.convertVariantEntityToResponse(variant),
+		})
+	}
+
 	productCheck.Stock = requestBody.Stock
+	productCheck.Version = version
 
 	// Call UpdateStock method of the database
 	product, err := p.Database.UpdateStock(c.UserContext(), productCheck, userID)
 
 	if err != nil {
+		if errors.Is(err, functions.ErrVariantRequired) {
+			return c.Status(http.StatusBadRequest).SendString(err.Error())
+		}
+		if errors.Is(err, functions.ErrVersionMismatch) {
+			return c.Status(http.StatusPreconditionFailed).SendString(err.Error())
+		}
 		if err.Error() == "data not found" {
 			return c.Status(http.StatusNotFound).SendString(err.Error())
 		}
@@ -554,6 +1042,11 @@ func (p *Product) DeleteProduct(c *fiber.Ctx) error {
 		return p.handleError(c, errors.New("failed parse product id"))
 	}
 
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
 	_, err = p.Database.FindByIDUser(c.UserContext(), productID, userID)
 	if err != nil {
 		if err == functions.ErrNoRow {
@@ -562,7 +1055,7 @@ func (p *Product) DeleteProduct(c *fiber.Ctx) error {
 		return p.handleError(c, err)
 	}
 
-	err = p.Database.DeleteByID(c.UserContext(), productID)
+	err = p.Database.DeleteByID(c.UserContext(), productID, userID, version)
 	if err != nil {
 		return p.handleError(c, err)
 	}
@@ -571,3 +1064,49 @@ func (p *Product) DeleteProduct(c *fiber.Ctx) error {
 		"message": "product deleted successfully",
 	})
 }
+
+func (p *Product) GetDeletedProducts(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	products, err := p.Database.FindDeletedByUserID(c.UserContext(), userID, time.Now().Add(-p.Retention))
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	result := []ProductResponse{}
+	for _, product := range products {
+		result = append(result, p.convertProductEntityToResponse(product))
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    result,
+	})
+}
+
+func (p *Product) RestoreProduct(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse product id"))
+	}
+
+	product, err := p.Database.Restore(c.UserContext(), productID, userID, time.Now().Add(-p.Retention))
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "product restored successfully",
+		"data":    p.convertProductEntityToResponse(product),
+	})
+}
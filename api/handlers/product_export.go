@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"shopifyx/db/entity"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+// exportFlushRows is the number of rows written between two flushes, a failed
+// flush means the client is gone and stops the export.
+const exportFlushRows = 100
+
+// productExportColumns are written after productCSVColumns, an import ignores them.
+var productExportColumns = []string{"productId", "purchaseCount", "createdAt", "updatedAt"}
+
+type (
+	QueryExportProducts struct {
+		Format string `json:"format"`
+	}
+
+	// ProductExportRow is a line of a jsonl export, it decodes as a ProductPayload.
+	ProductExportRow struct {
+		ProductId string `json:"productId"`
+		ProductPayload
+		PurchaseCount int       `json:"purchaseCount"`
+		CreatedAt     time.Time `json:"createdAt"`
+		UpdatedAt     time.Time `json:"updatedAt"`
+	}
+)
+
+func (app QueryExportProducts) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Format cannot be empty, and should be either "csv" or "jsonl".
+		validation.Field(&app.Format, validation.Required, validation.In("csv", "jsonl")),
+	)
+}
+
+func (p *Product) convertProductToExportRow(product entity.Product) ProductExportRow {
+	return ProductExportRow{
+		ProductId:      strconv.Itoa(product.ID),
+		ProductPayload: p.convertProductEntityToPayload(product),
+		PurchaseCount:  product.PurchaseCount,
+		CreatedAt:      product.CreatedAt,
+		UpdatedAt:      product.UpdatedAt,
+	}
+}
+
+func formatOptionalInt64(value *int64) string {
+	if value == nil {
+		return ""
+	}
+
+	return strconv.FormatInt(*value, 10)
+}
+
+func formatOptionalTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+
+	return value.Format(time.RFC3339)
+}
+
+func (row ProductExportRow) csvRecord() []string {
+	return []string{
+		row.Name,
+		strconv.FormatInt(row.Price, 10),
+		row.ImageURL,
+		strconv.Itoa(row.Stock),
+		row.Condition,
+		strings.Join(row.Tags, "|"),
+		strconv.FormatBool(row.IsPurchaseable),
+		row.CategoryId,
+		row.Currency,
+		strconv.Itoa(row.LowStockThreshold),
+		formatOptionalInt64(row.SalePrice),
+		formatOptionalTime(row.SaleStartsAt),
+		formatOptionalTime(row.SaleEndsAt),
+		row.Status,
+		formatOptionalTime(row.PublishAt),
+		row.ProductId,
+		strconv.Itoa(row.PurchaseCount),
+		row.CreatedAt.Format(time.RFC3339),
+		row.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func (p *Product) ExportProducts(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	var query QueryExportProducts
+	if err := c.QueryParser(&query); err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	err = query.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	filename := fmt.Sprintf("products-%s.%s", time.Now().Format("20060102"), query.Format)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if query.Format == "csv" {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	}
+
+	// the body is written after the handler returns, so it cannot use the
+	// request context
+	ctx := context.Background()
+	c.Status(http.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var write func(row ProductExportRow) error
+		flush := w.Flush
+		if query.Format == "csv" {
+			csvWriter := csv.NewWriter(w)
+			defer csvWriter.Flush()
+
+			if err := csvWriter.Write(append(append([]string{}, productCSVColumns...), productExportColumns...)); err != nil {
+				return
+			}
+
+			write = func(row ProductExportRow) error {
+				return csvWriter.Write(row.csvRecord())
+			}
+			flush = func() error {
+				csvWriter.Flush()
+				if err := csvWriter.Error(); err != nil {
+					return err
+				}
+				return w.Flush()
+			}
+		} else {
+			encoder := json.NewEncoder(w)
+			write = func(row ProductExportRow) error {
+				return encoder.Encode(row)
+			}
+		}
+
+		written := 0
+		err := p.Database.EachByUserID(ctx, userID, func(product entity.Product) error {
+			if err := write(p.convertProductToExportRow(product)); err != nil {
+				return err
+			}
+
+			written++
+			if written%exportFlushRows == 0 {
+				return flush()
+			}
+			return nil
+		})
+		if err != nil {
+			slog.Error("failed export products", "user_id", userID, "error", err.Error())
+		}
+	})
+
+	return nil
+}
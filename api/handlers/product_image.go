@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopifyx/db/entity"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	ProductImagePayload struct {
+		ImageURL string `json:"imageUrl"`
+		IsCover  bool   `json:"isCover"`
+	}
+
+	ProductImageOrderPayload struct {
+		ImageIds []string `json:"imageIds"`
+	}
+
+	ProductImageResponse struct {
+		ImageId  string `json:"imageId"`
+		ImageUrl string `json:"imageUrl"`
+		Position int    `json:"position"`
+		IsCover  bool   `json:"isCover"`
+	}
+)
+
+func (app ProductImagePayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// ImageURL cannot be empty and should be in a valid URL format.
+		validation.Field(&app.ImageURL, validation.Required, is.URL),
+	)
+}
+
+func (p *Product) convertImagesToResponse(images []entity.ProductImage) []ProductImageResponse {
+	result := []ProductImageResponse{}
+	for _, image := range images {
+		result = append(result, ProductImageResponse{
+			ImageId:  strconv.Itoa(image.ID),
+			ImageUrl: image.ImageUrl,
+			Position: image.Position,
+			IsCover:  image.IsCover,
+		})
+	}
+
+	return result
+}
+
+func (p *Product) GetImages(c *fiber.Ctx) error {
+	product, err := p.findVisibleProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	images, err := p.ImageDatabase.FindByProductID(c.UserContext(), product.ID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    p.convertImagesToResponse(images),
+	})
+}
+
+func (p *Product) AddImage(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var payload ProductImagePayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	image, err := p.ImageDatabase.Add(c.UserContext(), userID, entity.ProductImage{
+		ProductID: product.ID,
+		ImageUrl:  payload.ImageURL,
+		IsCover:   payload.IsCover,
+	})
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(map[string]interface{}{
+		"message": "image added successfully",
+		"data":    p.convertImagesToResponse([]entity.ProductImage{image})[0],
+	})
+}
+
+func (p *Product) SetCoverImage(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	imageID, err := strconv.Atoi(c.Params("imageId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse image id"))
+	}
+
+	err = p.ImageDatabase.SetCover(c.UserContext(), userID, product.ID, imageID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "cover image updated successfully",
+	})
+}
+
+func (p *Product) ReorderImages(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var payload ProductImageOrderPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	imageIDs := []int{}
+	for _, id := range payload.ImageIds {
+		imageID, err := strconv.Atoi(id)
+		if err != nil {
+			return p.handleError(c, errors.New("failed parse image id"))
+		}
+		imageIDs = append(imageIDs, imageID)
+	}
+
+	err = p.ImageDatabase.Reorder(c.UserContext(), userID, product.ID, imageIDs)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	images, err := p.ImageDatabase.FindByProductID(c.UserContext(), product.ID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "images reordered successfully",
+		"data":    p.convertImagesToResponse(images),
+	})
+}
+
+func (p *Product) DeleteImage(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	imageID, err := strconv.Atoi(c.Params("imageId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse image id"))
+	}
+
+	err = p.ImageDatabase.Delete(c.UserContext(), userID, product.ID, imageID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "image deleted successfully",
+	})
+}
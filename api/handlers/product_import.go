@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"shopifyx/db/entity"
+	"shopifyx/internal/money"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxImportRows caps the number of products accepted by a single import.
+const maxImportRows = 1000
+
+// productCSVColumns are the columns read by an import, tags are separated by "|".
+var productCSVColumns = []string{"name", "price", "imageUrl", "stock", "condition", "tags", "isPurchaseable", "categoryId", "currency", "lowStockThreshold", "salePrice", "saleStartsAt", "saleEndsAt", "status", "publishAt"}
+
+// optionalCSVColumns may be left out of an import file.
+var optionalCSVColumns = []string{"categoryId", "currency", "lowStockThreshold", "salePrice", "saleStartsAt", "saleEndsAt", "status", "publishAt"}
+
+type (
+	QueryImportProducts struct {
+		Format string `json:"format"`
+		DryRun bool   `json:"dryRun"`
+	}
+
+	ProductImportRowResponse struct {
+		Row       int    `json:"row"`
+		Status    string `json:"status"`
+		ProductId string `json:"productId,omitempty"`
+		Error     string `json:"error,omitempty"`
+	}
+
+	ProductImportResponse struct {
+		DryRun  bool                       `json:"dryRun"`
+		Created int                        `json:"created"`
+		Updated int                        `json:"updated"`
+		Failed  int                        `json:"failed"`
+		Rows    []ProductImportRowResponse `json:"rows"`
+	}
+
+	// importRow is a parsed row of an import file, Err is set when the row
+	// could not be parsed or validated.
+	importRow struct {
+		Payload ProductPayload
+		Err     error
+	}
+)
+
+func (app QueryImportProducts) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Format should be either "csv" or "jsonl".
+		validation.Field(&app.Format, validation.In("csv", "jsonl")),
+	)
+}
+
+// validationMessage flattens ozzo validation errors into a single message.
+func validationMessage(err error) string {
+	validationErrors, ok := err.(validation.Errors)
+	if !ok {
+		return err.Error()
+	}
+
+	keys := []string{}
+	for key := range validationErrors {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	errMessages := []string{}
+	for _, key := range keys {
+		errMessages = append(errMessages, fmt.Sprintf("field %s: %s", key, validationErrors[key].Error()))
+	}
+
+	return strings.Join(errMessages, ", ")
+}
+
+// importFormat picks the format from the query, falling back to the content type.
+func importFormat(c *fiber.Ctx, format string) string {
+	if format != "" {
+		return format
+	}
+
+	contentType := c.Get(fiber.HeaderContentType)
+	switch {
+	case strings.Contains(contentType, "csv"):
+		return "csv"
+	case strings.Contains(contentType, "ndjson"), strings.Contains(contentType, "jsonl"):
+		return "jsonl"
+	}
+
+	return ""
+}
+
+// importBody returns the uploaded "file" of a multipart request, or the raw body.
+func importBody(c *fiber.Ctx) (io.Reader, error) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		return bytes.NewReader(c.Body()), nil
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+func parseCSVRow(header map[string]int, record []string) (ProductPayload, error) {
+	field := func(name string) string {
+		if i, ok := header[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	payload := ProductPayload{
+		Name:       field("name"),
+		ImageURL:   field("imageUrl"),
+		Condition:  field("condition"),
+		CategoryId: field("categoryId"),
+		Currency:   field("currency"),
+		Status:     field("status"),
+	}
+
+	var err error
+	if payload.Price, err = strconv.ParseInt(field("price"), 10, 64); err != nil {
+		return payload, errors.New("field price: must be a number")
+	}
+
+	if payload.Stock, err = strconv.Atoi(field("stock")); err != nil {
+		return payload, errors.New("field stock: must be a number")
+	}
+
+	if payload.IsPurchaseable, err = strconv.ParseBool(field("isPurchaseable")); err != nil {
+		return payload, errors.New("field isPurchaseable: must be true or false")
+	}
+
+	for _, tag := range strings.Split(field("tags"), "|") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			payload.Tags = append(payload.Tags, tag)
+		}
+	}
+
+	if value := field("lowStockThreshold"); value != "" {
+		if payload.LowStockThreshold, err = strconv.Atoi(value); err != nil {
+			return payload, errors.New("field lowStockThreshold: must be a number")
+		}
+	}
+
+	if value := field("salePrice"); value != "" {
+		salePrice, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return payload, errors.New("field salePrice: must be a number")
+		}
+		payload.SalePrice = &salePrice
+	}
+
+	if value := field("saleStartsAt"); value != "" {
+		saleStartsAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return payload, errors.New("field saleStartsAt: must be an RFC 3339 time")
+		}
+		payload.SaleStartsAt = &saleStartsAt
+	}
+
+	if value := field("saleEndsAt"); value != "" {
+		saleEndsAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return payload, errors.New("field saleEndsAt: must be an RFC 3339 time")
+		}
+		payload.SaleEndsAt = &saleEndsAt
+	}
+
+	if value := field("publishAt"); value != "" {
+		publishAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return payload, errors.New("field publishAt: must be an RFC 3339 time")
+		}
+		payload.PublishAt = &publishAt
+	}
+
+	return payload, nil
+}
+
+func parseCSVImport(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	headerRecord, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("failed parse payload: missing csv header")
+	}
+
+	header := map[string]int{}
+	for i, column := range headerRecord {
+		header[strings.TrimSpace(column)] = i
+	}
+
+	for _, column := range productCSVColumns {
+		if _, ok := header[column]; !ok && !slices.Contains(optionalCSVColumns, column) {
+			return nil, fmt.Errorf("failed parse payload: missing csv column %s", column)
+		}
+	}
+
+	rows := []importRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rows = append(rows, importRow{Err: err})
+			continue
+		}
+
+		payload, err := parseCSVRow(header, record)
+		rows = append(rows, importRow{Payload: payload, Err: err})
+	}
+
+	return rows, nil
+}
+
+func parseJSONLImport(r io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	rows := []importRow{}
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var payload ProductPayload
+		err := json.Unmarshal(line, &payload)
+		rows = append(rows, importRow{Payload: payload, Err: err})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed parse payload: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (p *Product) ImportProducts(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	var query QueryImportProducts
+	if err := c.QueryParser(&query); err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	query.Format = importFormat(c, query.Format)
+	if query.Format == "" {
+		return p.handleError(c, errors.New("failed parse payload: format should be csv or jsonl"))
+	}
+
+	err = query.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	body, err := importBody(c)
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	var rows []importRow
+	if query.Format == "csv" {
+		rows, err = parseCSVImport(body)
+	} else {
+		rows, err = parseJSONLImport(body)
+	}
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	if len(rows) > maxImportRows {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse payload: at most %d rows can be imported at once", maxImportRows)))
+	}
+
+	report := ProductImportResponse{
+		DryRun: query.DryRun,
+		Rows:   make([]ProductImportRowResponse, len(rows)),
+	}
+
+	// only rows that pass validation reach the database
+	products := []entity.Product{}
+	productRows := []int{}
+	for i, row := range rows {
+		report.Rows[i].Row = i + 1
+
+		if row.Err == nil {
+			row.Payload.Tags = p.TagAliases.Normalize(row.Payload.Tags)
+			row.Err = row.Payload.Validate()
+		}
+
+		if row.Err != nil {
+			report.Rows[i].Status = entity.ProductImportFailed
+			report.Rows[i].Error = validationMessage(row.Err)
+			continue
+		}
+
+		products = append(products, entity.Product{
+			Name:              row.Payload.Name,
+			Price:             money.New(row.Payload.Price, row.Payload.Currency),
+			ImageUrl:          row.Payload.ImageURL,
+			Stock:             row.Payload.Stock,
+			Condition:         row.Payload.Condition,
+			Tags:              row.Payload.Tags,
+			IsPurchaseable:    row.Payload.IsPurchaseable,
+			CategoryID:        row.Payload.categoryID(),
+			Status:            row.Payload.Status,
+			PublishAt:         row.Payload.PublishAt,
+			LowStockThreshold: row.Payload.LowStockThreshold,
+			SalePrice:         row.Payload.SalePrice,
+			SaleStartsAt:      row.Payload.SaleStartsAt,
+			SaleEndsAt:        row.Payload.SaleEndsAt,
+		})
+		productRows = append(productRows, i)
+	}
+
+	results, err := p.Database.Import(c.UserContext(), userID, products, query.DryRun)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	for i, result := range results {
+		row := &report.Rows[productRows[i]]
+		row.Status = result.Action
+		row.Error = result.Error
+		if result.ProductID != 0 {
+			row.ProductId = strconv.Itoa(result.ProductID)
+		}
+	}
+
+	for _, row := range report.Rows {
+		switch row.Status {
+		case entity.ProductImportCreated:
+			report.Created++
+		case entity.ProductImportUpdated:
+			report.Updated++
+		default:
+			report.Failed++
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    report,
+	})
+}
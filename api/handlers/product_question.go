@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopifyx/db/entity"
+	"strconv"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+// detailQuestionLimit is the number of answered questions shown on the
+// product detail.
+const detailQuestionLimit = 5
+
+type (
+	QueryFilterGetQuestions struct {
+		Limit    int  `json:"limit"`
+		Offset   int  `json:"offset"`
+		Answered bool `json:"answered"`
+	}
+
+	ProductQuestionPayload struct {
+		Question string `json:"question"`
+	}
+
+	ProductQuestionAnswerPayload struct {
+		Answer string `json:"answer"`
+	}
+
+	ProductQuestionModerationPayload struct {
+		IsHidden *bool `json:"isHidden"`
+	}
+
+	ProductQuestionResponse struct {
+		QuestionId string     `json:"questionId"`
+		UserId     string     `json:"userId"`
+		Question   string     `json:"question"`
+		Answer     *string    `json:"answer,omitempty"`
+		AnsweredAt *time.Time `json:"answeredAt,omitempty"`
+		IsHidden   bool       `json:"isHidden,omitempty"`
+		CreatedAt  time.Time  `json:"createdAt"`
+		UpdatedAt  time.Time  `json:"updatedAt"`
+	}
+)
+
+func (app QueryFilterGetQuestions) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Limit should be between 0 and 100.
+		validation.Field(&app.Limit, validation.Min(0), validation.Max(100)),
+		// Offset should be greater than 0.
+		validation.Field(&app.Offset, validation.Min(0)),
+	)
+}
+
+func (app ProductQuestionPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Question cannot be empty, and the length must be at most 1000.
+		validation.Field(&app.Question, validation.Required, validation.Length(1, 1000)),
+	)
+}
+
+func (app ProductQuestionAnswerPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Answer cannot be empty, and the length must be at most 2000.
+		validation.Field(&app.Answer, validation.Required, validation.Length(1, 2000)),
+	)
+}
+
+func (app ProductQuestionModerationPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// IsHidden cannot be empty.
+		validation.Field(&app.IsHidden, validation.NotNil),
+	)
+}
+
+func (p *Product) convertQuestionEntityToResponse(question entity.ProductQuestion) ProductQuestionResponse {
+	return ProductQuestionResponse{
+		QuestionId: strconv.Itoa(question.ID),
+		UserId:     strconv.Itoa(question.UserID),
+		Question:   question.Question,
+		Answer:     question.Answer,
+		AnsweredAt: question.AnsweredAt,
+		IsHidden:   question.IsHidden,
+		CreatedAt:  question.CreatedAt,
+		UpdatedAt:  question.UpdatedAt,
+	}
+}
+
+func (p *Product) convertQuestionsToResponse(questions []entity.ProductQuestion) []ProductQuestionResponse {
+	result := []ProductQuestionResponse{}
+	for _, question := range questions {
+		result = append(result, p.convertQuestionEntityToResponse(question))
+	}
+
+	return result
+}
+
+func (p *Product) GetQuestions(c *fiber.Ctx) error {
+	product, err := p.findVisibleProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var filter QueryFilterGetQuestions
+	if err := c.QueryParser(&filter); err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	questions, total, err := p.QuestionDatabase.FindByProductID(c.UserContext(), product.ID, filter.Answered, filter.Limit, filter.Offset)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    p.convertQuestionsToResponse(questions),
+		"meta": Meta{
+			Limit:  filter.Limit,
+			Offset: filter.Offset,
+			Total:  total,
+		},
+	})
+}
+
+// AskQuestion posts a question on a product, the seller is notified.
+func (p *Product) AskQuestion(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	product, err := p.findVisibleProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var payload ProductQuestionPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	question, err := p.QuestionDatabase.Add(c.UserContext(), entity.ProductQuestion{
+		ProductID: product.ID,
+		UserID:    userID,
+		Question:  payload.Question,
+	})
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(map[string]interface{}{
+		"message": "question added successfully",
+		"data":    p.convertQuestionEntityToResponse(question),
+	})
+}
+
+// AnswerQuestion lets the seller answer a question on their product.
+func (p *Product) AnswerQuestion(c *fiber.Ctx) error {
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	questionID, err := strconv.Atoi(c.Params("questionId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse question id"))
+	}
+
+	var payload ProductQuestionAnswerPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	question, err := p.QuestionDatabase.Answer(c.UserContext(), product.ID, questionID, payload.Answer)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "question answered successfully",
+		"data":    p.convertQuestionEntityToResponse(question),
+	})
+}
+
+// ModerateQuestion hides or restores a question, admins only so sellers
+// cannot bury questions about their own products.
+func (p *Product) ModerateQuestion(c *fiber.Ctx) error {
+	if err := p.requireAdmin(c); err != nil {
+		return p.handleError(c, err)
+	}
+
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse product id"))
+	}
+
+	questionID, err := strconv.Atoi(c.Params("questionId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse question id"))
+	}
+
+	var payload ProductQuestionModerationPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	question, err := p.QuestionDatabase.Moderate(c.UserContext(), productID, questionID, *payload.IsHidden)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "question moderated successfully",
+		"data":    p.convertQuestionEntityToResponse(question),
+	})
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+// relatedPerSeller caps the related products shown from a single seller.
+const relatedPerSeller = 3
+
+type (
+	QueryFilterGetRelated struct {
+		Limit int `json:"limit"`
+	}
+)
+
+func (app QueryFilterGetRelated) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Limit should be between 0 and 50.
+		validation.Field(&app.Limit, validation.Min(0), validation.Max(50)),
+	)
+}
+
+// GetRelatedProducts recommends products to keep browsing from a product page.
+func (p *Product) GetRelatedProducts(c *fiber.Ctx) error {
+	product, err := p.findVisibleProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var filter QueryFilterGetRelated
+	if err := c.QueryParser(&filter); err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 10
+	}
+
+	var userID int
+	if c.Locals("user_id") != nil {
+		userID, err = strconv.Atoi(c.Locals("user_id").(string))
+		if err != nil {
+			return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+		}
+	}
+
+	products, err := p.Database.FindRelated(c.UserContext(), product, filter.Limit, relatedPerSeller)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	result := []ProductResponse{}
+	for _, related := range products {
+		result = append(result, p.convertProductEntityToResponse(related))
+	}
+
+	err = p.convertDisplayPrices(c, c.Query("currency"), result)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	if userID != 0 && len(result) > 0 {
+		err = p.markWishlisted(c, userID, result)
+		if err != nil {
+			return p.handleError(c, err)
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    result,
+	})
+}
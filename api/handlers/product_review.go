@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopifyx/db/entity"
+	"strconv"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	ProductReviewPayload struct {
+		Rating int      `json:"rating"`
+		Body   string   `json:"body"`
+		Images []string `json:"images"`
+	}
+
+	ProductReviewReplyPayload struct {
+		Reply string `json:"reply"`
+	}
+
+	ProductReviewFlagPayload struct {
+		Reason string `json:"reason"`
+	}
+
+	ProductReviewModerationPayload struct {
+		IsHidden *bool `json:"isHidden"`
+	}
+
+	ProductReviewResponse struct {
+		ReviewId  string     `json:"reviewId"`
+		UserId    string     `json:"userId"`
+		Rating    int        `json:"rating"`
+		Body      string     `json:"body"`
+		Images    []string   `json:"images"`
+		Reply     *string    `json:"reply,omitempty"`
+		RepliedAt *time.Time `json:"repliedAt,omitempty"`
+		FlagCount int        `json:"flagCount,omitempty"`
+		IsHidden  bool       `json:"isHidden,omitempty"`
+		CreatedAt time.Time  `json:"createdAt"`
+		UpdatedAt time.Time  `json:"updatedAt"`
+	}
+)
+
+func (app ProductReviewPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Rating cannot be empty, and should be between 1 and 5.
+		validation.Field(&app.Rating, validation.Required, validation.Min(1), validation.Max(5)),
+		// Body is optional, and the length must be at most 2000.
+		validation.Field(&app.Body, validation.Length(0, 2000)),
+		// Images is optional, at most 5 urls.
+		validation.Field(&app.Images, validation.Length(0, 5), validation.Each(validation.Required, is.URL)),
+	)
+}
+
+func (app ProductReviewReplyPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Reply cannot be empty, and the length must be at most 2000.
+		validation.Field(&app.Reply, validation.Required, validation.Length(1, 2000)),
+	)
+}
+
+func (app ProductReviewFlagPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Reason is optional, and the length must be at most 255.
+		validation.Field(&app.Reason, validation.Length(0, 255)),
+	)
+}
+
+func (app ProductReviewModerationPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// IsHidden cannot be empty.
+		validation.Field(&app.IsHidden, validation.NotNil),
+	)
+}
+
+func (p *Product) convertReviewEntityToResponse(review entity.ProductReview) ProductReviewResponse {
+	images := review.Images
+	if images == nil {
+		images = []string{}
+	}
+
+	return ProductReviewResponse{
+		ReviewId:  strconv.Itoa(review.ID),
+		UserId:    strconv.Itoa(review.UserID),
+		Rating:    review.Rating,
+		Body:      review.Body,
+		Images:    images,
+		Reply:     review.Reply,
+		RepliedAt: review.RepliedAt,
+		FlagCount: review.FlagCount,
+		IsHidden:  review.IsHidden,
+		CreatedAt: review.CreatedAt,
+		UpdatedAt: review.UpdatedAt,
+	}
+}
+
+// requireAdmin only lets users flagged as admin through.
+func (p *Product) requireAdmin(c *fiber.Ctx) error {
+	user, err := p.UserDatabase.GetUserById(c.UserContext(), c.Locals("user_id").(string))
+	if err != nil {
+		return fiber.ErrUnauthorized
+	}
+
+	if !user.IsAdmin {
+		return fiber.ErrForbidden
+	}
+
+	return nil
+}
+
+func (p *Product) GetReviews(c *fiber.Ctx) error {
+	product, err := p.findVisibleProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var filter QueryFilterGetHistory
+	if err := c.QueryParser(&filter); err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	reviews, total, err := p.ReviewDatabase.FindByProductID(c.UserContext(), product.ID, filter.Limit, filter.Offset)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	result := []ProductReviewResponse{}
+	for _, review := range reviews {
+		response := p.convertReviewEntityToResponse(review)
+		// flags are only shown to moderators
+		response.FlagCount = 0
+		result = append(result, response)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    result,
+		"meta": Meta{
+			Limit:  filter.Limit,
+			Offset: filter.Offset,
+			Total:  total,
+		},
+	})
+}
+
+// AddReview posts a review, only buyers of the product can review it.
+func (p *Product) AddReview(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse product id"))
+	}
+
+	var payload ProductReviewPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	product, err := p.Database.FindByID(c.UserContext(), productID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	review, err := p.ReviewDatabase.Add(c.UserContext(), entity.ProductReview{
+		ProductID: product.ID,
+		UserID:    userID,
+		Rating:    payload.Rating,
+		Body:      payload.Body,
+		Images:    payload.Images,
+	})
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(map[string]interface{}{
+		"message": "review added successfully",
+		"data":    p.convertReviewEntityToResponse(review),
+	})
+}
+
+// ReplyReview lets the seller answer a review of their product.
+func (p *Product) ReplyReview(c *fiber.Ctx) error {
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	reviewID, err := strconv.Atoi(c.Params("reviewId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse review id"))
+	}
+
+	var payload ProductReviewReplyPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	review, err := p.ReviewDatabase.Reply(c.UserContext(), product.ID, reviewID, payload.Reply)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "review replied successfully",
+		"data":    p.convertReviewEntityToResponse(review),
+	})
+}
+
+// FlagReview reports a review for moderation.
+func (p *Product) FlagReview(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse product id"))
+	}
+
+	reviewID, err := strconv.Atoi(c.Params("reviewId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse review id"))
+	}
+
+	var payload ProductReviewFlagPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	err = p.ReviewDatabase.Flag(c.UserContext(), productID, reviewID, userID, payload.Reason)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "review flagged successfully",
+	})
+}
+
+// ModerateReview hides or restores a review, admins only.
+func (p *Product) ModerateReview(c *fiber.Ctx) error {
+	if err := p.requireAdmin(c); err != nil {
+		return p.handleError(c, err)
+	}
+
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse product id"))
+	}
+
+	reviewID, err := strconv.Atoi(c.Params("reviewId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse review id"))
+	}
+
+	var payload ProductReviewModerationPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	review, err := p.ReviewDatabase.Moderate(c.UserContext(), productID, reviewID, *payload.IsHidden)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "review moderated successfully",
+		"data":    p.convertReviewEntityToResponse(review),
+	})
+}
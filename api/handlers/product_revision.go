@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"shopifyx/db/entity"
+	"slices"
+	"strconv"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	QueryFilterGetHistory struct {
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	}
+
+	ProductRevisionResponse struct {
+		RevisionId string          `json:"revisionId"`
+		Action     string          `json:"action"`
+		UserId     string          `json:"userId,omitempty"`
+		Changes    []string        `json:"changes"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+		CreatedAt  time.Time       `json:"createdAt"`
+	}
+)
+
+func (app QueryFilterGetHistory) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Limit should be between 0 and 100.
+		validation.Field(&app.Limit, validation.Min(0), validation.Max(100)),
+		// Offset should be greater than 0.
+		validation.Field(&app.Offset, validation.Min(0)),
+	)
+}
+
+// changedFields lists the top level fields that differ between two snapshots,
+// updated_at and version are left out as they change on every revision.
+func changedFields(before, after json.RawMessage) []string {
+	var b, a map[string]json.RawMessage
+	_ = json.Unmarshal(before, &b)
+	_ = json.Unmarshal(after, &a)
+
+	changes := []string{}
+	for key, value := range a {
+		if key == "updated_at" || key == "version" {
+			continue
+		}
+		if !bytes.Equal(b[key], value) {
+			changes = append(changes, key)
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			changes = append(changes, key)
+		}
+	}
+
+	slices.Sort(changes)
+	return changes
+}
+
+func (p *Product) convertRevisionEntityToResponse(revision entity.ProductRevision) ProductRevisionResponse {
+	var userID string
+	if revision.UserID != nil {
+		userID = strconv.Itoa(*revision.UserID)
+	}
+
+	return ProductRevisionResponse{
+		RevisionId: strconv.Itoa(revision.ID),
+		Action:     revision.Action,
+		UserId:     userID,
+		Changes:    changedFields(revision.Before, revision.After),
+		Before:     revision.Before,
+		After:      revision.After,
+		CreatedAt:  revision.CreatedAt,
+	}
+}
+
+func (p *Product) GetHistory(c *fiber.Ctx) error {
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var filter QueryFilterGetHistory
+	if err := c.QueryParser(&filter); err != nil {
+		return p.handleError(c, errors.New("failed parse payload"))
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	revisions, total, err := p.HistoryDatabase.FindByProductID(c.UserContext(), product.ID, filter.Limit, filter.Offset)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	result := []ProductRevisionResponse{}
+	for _, revision := range revisions {
+		result = append(result, p.convertRevisionEntityToResponse(revision))
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    result,
+		"meta": Meta{
+			Limit:  filter.Limit,
+			Offset: filter.Offset,
+			Total:  total,
+		},
+	})
+}
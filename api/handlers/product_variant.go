@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopifyx/db/entity"
+	"shopifyx/db/functions"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	ProductVariantPayload struct {
+		SKU      string            `json:"sku"`
+		Options  map[string]string `json:"options"`
+		Price    int64             `json:"price"`
+		Stock    int               `json:"stock"`
+		ImageURL string            `json:"imageUrl"`
+	}
+
+	ProductVariantResponse struct {
+		VariantId     string            `json:"variantId"`
+		SKU           string            `json:"sku"`
+		Options       map[string]string `json:"options"`
+		Price         int64             `json:"price"`
+		Stock         int               `json:"stock"`
+		HeldStock     int               `json:"heldStock,omitempty"`
+		ImageUrl      string            `json:"imageUrl,omitempty"`
+		PurchaseCount int               `json:"purchaseCount"`
+	}
+)
+
+func (app ProductVariantPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// SKU cannot be empty, and the length must be between 1 and 60.
+		validation.Field(&app.SKU, validation.Required, validation.Length(1, 60)),
+		// Options should describe the variant, e.g. {"size": "XL"}.
+		validation.Field(&app.Options, validation.Required),
+		// Price cannot be empty, and should be greater than 0.
+		validation.Field(&app.Price, validation.NotNil, validation.Min(0)),
+		// Stock cannot be empty, and should be greater than 0.
+		validation.Field(&app.Stock, validation.NotNil, validation.Min(0)),
+		// ImageURL is optional and should be in a valid URL format.
+		validation.Field(&app.ImageURL, is.URL),
+	)
+}
+
+func (p *Product) convertVariantEntityToResponse(variant entity.ProductVariant) ProductVariantResponse {
+	return ProductVariantResponse{
+		VariantId:     strconv.Itoa(variant.ID),
+		SKU:           variant.SKU,
+		Options:       variant.Options,
+		Price:         variant.Price,
+		Stock:         availableStock(variant.Stock, variant.HeldStock),
+		HeldStock:     variant.HeldStock,
+		ImageUrl:      variant.ImageUrl,
+		PurchaseCount: variant.PurchaseCount,
+	}
+}
+
+func (p *Product) convertVariantsToResponse(variants []entity.ProductVariant) []ProductVariantResponse {
+	result := []ProductVariantResponse{}
+	for _, variant := range variants {
+		result = append(result, p.convertVariantEntityToResponse(variant))
+	}
+
+	return result
+}
+
+// findOwnedProduct resolves the :id param to a product owned by the caller.
+func (p *Product) findOwnedProduct(c *fiber.Ctx) (entity.Product, error) {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return entity.Product{}, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error()))
+	}
+
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return entity.Product{}, errors.New("failed parse product id")
+	}
+
+	product, err := p.Database.FindByIDUser(c.UserContext(), productID, userID)
+	if err != nil {
+		if err == functions.ErrNoRow {
+			return entity.Product{}, fiber.ErrForbidden
+		}
+		return entity.Product{}, err
+	}
+
+	return product, nil
+}
+
+// findVisibleProduct resolves the product of the request, unpublished
+// products are only visible to their seller.
+func (p *Product) findVisibleProduct(c *fiber.Ctx) (entity.Product, error) {
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return entity.Product{}, errors.New("failed parse product id")
+	}
+
+	product, err := p.Database.FindByID(c.UserContext(), productID)
+	if err != nil {
+		return entity.Product{}, err
+	}
+
+	if product.Status != entity.ProductStatusPublished {
+		if c.Locals("user_id") == nil || c.Locals("user_id").(string) != strconv.Itoa(product.UserID) {
+			return entity.Product{}, functions.ErrNoRow
+		}
+	}
+
+	return product, nil
+}
+
+func (p *Product) GetVariants(c *fiber.Ctx) error {
+	product, err := p.findVisibleProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	variants, err := p.VariantDatabase.FindByProductID(c.UserContext(), product.ID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    p.convertVariantsToResponse(variants),
+	})
+}
+
+func (p *Product) AddVariant(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var payload ProductVariantPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	variant, err := p.VariantDatabase.Add(c.UserContext(), userID, entity.ProductVariant{
+		ProductID: product.ID,
+		SKU:       payload.SKU,
+		Options:   payload.Options,
+		Price:     payload.Price,
+		Stock:     payload.Stock,
+		ImageUrl:  payload.ImageURL,
+	})
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(map[string]interface{}{
+		"message": "variant created successfully",
+		"data":    p.convertVariantEntityToResponse(variant),
+	})
+}
+
+func (p *Product) UpdateVariant(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	variantID, err := strconv.Atoi(c.Params("variantId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse variant id"))
+	}
+
+	var payload ProductVariantPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	variant, err := p.VariantDatabase.Update(c.UserContext(), userID, entity.ProductVariant{
+		ID:        variantID,
+		ProductID: product.ID,
+		SKU:       payload.SKU,
+		Options:   payload.Options,
+		Price:     payload.Price,
+		Stock:     payload.Stock,
+		ImageUrl:  payload.ImageURL,
+	})
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "variant updated successfully",
+		"data":    p.convertVariantEntityToResponse(variant),
+	})
+}
+
+func (p *Product) DeleteVariant(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	variantID, err := strconv.Atoi(c.Params("variantId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse variant id"))
+	}
+
+	err = p.VariantDatabase.Delete(c.UserContext(), userID, product.ID, variantID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "variant deleted successfully",
+	})
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopifyx/db/entity"
+	"strconv"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	StockAdjustmentPayload struct {
+		Delta     int    `json:"delta"`
+		Type      string `json:"type"`
+		Reason    string `json:"reason"`
+		VariantId string `json:"variantId"`
+	}
+
+	StockMovementResponse struct {
+		MovementId string    `json:"movementId"`
+		VariantId  string    `json:"variantId,omitempty"`
+		UserId     string    `json:"userId,omitempty"`
+		Type       string    `json:"type"`
+		Delta      int       `json:"delta"`
+		StockAfter int       `json:"stockAfter"`
+		Reason     string    `json:"reason"`
+		PaymentId  string    `json:"paymentId,omitempty"`
+		CreatedAt  time.Time `json:"createdAt"`
+	}
+)
+
+func (app StockAdjustmentPayload) Validate() error {
+	deltaRules := []validation.Rule{validation.Required}
+	if app.Type == entity.StockMovementRestock || app.Type == entity.StockMovementReturn {
+		deltaRules = append(deltaRules, validation.Min(1))
+	}
+
+	return validation.ValidateStruct(&app,
+		// Delta cannot be empty, and should be positive for a restock or a return.
+		validation.Field(&app.Delta, deltaRules...),
+		// Type cannot be empty, and should be either "restock", "return", "adjustment" or "correction".
+		validation.Field(&app.Type, validation.Required, validation.In(
+			entity.StockMovementRestock, entity.StockMovementReturn, entity.StockMovementAdjustment, entity.StockMovementCorrection,
+		)),
+		// Reason is optional, and the length must be at most 255.
+		validation.Field(&app.Reason, validation.Length(0, 255)),
+		// VariantId is required for products with variants and should be numeric.
+		validation.Field(&app.VariantId, is.Digit),
+	)
+}
+
+func (p *Product) convertMovementEntityToResponse(movement entity.StockMovement) StockMovementResponse {
+	response := StockMovementResponse{
+		MovementId: strconv.Itoa(movement.ID),
+		Type:       movement.Type,
+		Delta:      movement.Delta,
+		StockAfter: movement.StockAfter,
+		Reason:     movement.Reason,
+		CreatedAt:  movement.CreatedAt,
+	}
+
+	if movement.VariantID != nil {
+		response.VariantId = strconv.Itoa(*movement.VariantID)
+	}
+
+	if movement.UserID != nil {
+		response.UserId = strconv.Itoa(*movement.UserID)
+	}
+
+	if movement.PaymentID != nil {
+		response.PaymentId = strconv.Itoa(*movement.PaymentID)
+	}
+
+	return response
+}
+
+// AdjustStock changes the stock by a relative amount, e.g. +5 on a restock.
+func (p *Product) AdjustStock(c *fiber.Ctx) error {
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var payload StockAdjustmentPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var variantID *int
+	if payload.VariantId != "" {
+		id, _ := strconv.Atoi(payload.VariantId)
+		variantID = &id
+	}
+
+	movement, err := p.MovementDatabase.Adjust(c.UserContext(), product.UserID, entity.StockMovement{
+		ProductID: product.ID,
+		VariantID: variantID,
+		Type:      payload.Type,
+		Delta:     payload.Delta,
+		Reason:    payload.Reason,
+	}, version)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "stock adjusted successfully",
+		"data":    p.convertMovementEntityToResponse(movement),
+	})
+}
+
+func (p *Product) GetStockMovements(c *fiber.Ctx) error {
+	product, err := p.findOwnedProduct(c)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var filter QueryFilterGetHistory
+	if err := c.QueryParser(&filter); err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	movements, total, err := p.MovementDatabase.FindByProductID(c.UserContext(), product.ID, filter.Limit, filter.Offset)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	result := []StockMovementResponse{}
+	for _, movement := range movements {
+		result = append(result, p.convertMovementEntityToResponse(movement))
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    result,
+		"meta": Meta{
+			Limit:  filter.Limit,
+			Offset: filter.Offset,
+			Total:  total,
+		},
+	})
+}
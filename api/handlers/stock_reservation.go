@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopifyx/db/entity"
+	"strconv"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	StockReservationPayload struct {
+		Qty       int    `json:"quantity"`
+		VariantId string `json:"variantId"`
+	}
+
+	StockReservationResponse struct {
+		ReservationId string    `json:"reservationId"`
+		ProductId     string    `json:"productId"`
+		VariantId     string    `json:"variantId,omitempty"`
+		Qty           int       `json:"quantity"`
+		ExpiresAt     time.Time `json:"expiresAt"`
+	}
+)
+
+func (app StockReservationPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Qty cannot be empty, and should be at least 1.
+		validation.Field(&app.Qty, validation.Required, validation.Min(1)),
+		// VariantId is required for products with variants and should be numeric.
+		validation.Field(&app.VariantId, is.Digit),
+	)
+}
+
+// availableStock is the stock that is not held by reservations.
+func availableStock(stock, held int) int {
+	return max(stock-held, 0)
+}
+
+func (p *Product) convertReservationEntityToResponse(reservation entity.StockReservation) StockReservationResponse {
+	var variantID string
+	if reservation.VariantID != nil {
+		variantID = strconv.Itoa(*reservation.VariantID)
+	}
+
+	return StockReservationResponse{
+		ReservationId: strconv.Itoa(reservation.ID),
+		ProductId:     strconv.Itoa(reservation.ProductID),
+		VariantId:     variantID,
+		Qty:           reservation.Qty,
+		ExpiresAt:     reservation.ExpiresAt,
+	}
+}
+
+func (p *Product) ReserveStock(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse product id"))
+	}
+
+	var payload StockReservationPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = payload.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	var variantID *int
+	if payload.VariantId != "" {
+		id, _ := strconv.Atoi(payload.VariantId)
+		variantID = &id
+	}
+
+	reservation, err := p.ReservationDatabase.Reserve(c.UserContext(), entity.StockReservation{
+		ProductID: productID,
+		VariantID: variantID,
+		UserID:    userID,
+		Qty:       payload.Qty,
+		ExpiresAt: time.Now().Add(p.ReservationTTL),
+	}, p.ReservationMaxQty)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(map[string]interface{}{
+		"message": "stock reserved successfully",
+		"data":    p.convertReservationEntityToResponse(reservation),
+	})
+}
+
+func (p *Product) ReleaseReservation(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse product id"))
+	}
+
+	reservationID, err := strconv.Atoi(c.Params("reservationId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse reservation id"))
+	}
+
+	err = p.ReservationDatabase.Release(c.UserContext(), productID, reservationID, userID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "reservation released successfully",
+	})
+}
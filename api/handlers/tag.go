@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopifyx/api/responses"
+	"shopifyx/db/functions"
+	"shopifyx/internal/tags"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	Tag struct {
+		Database *functions.Product
+	}
+
+	QueryFilterGetTags struct {
+		Prefix string `json:"prefix"`
+		Limit  int    `json:"limit"`
+	}
+
+	TagResponse struct {
+		Tag   string `json:"tag"`
+		Count int    `json:"count"`
+	}
+)
+
+func (app QueryFilterGetTags) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Prefix is optional, and the length must be at most 60.
+		validation.Field(&app.Prefix, validation.Length(0, 60)),
+		// Limit should be between 0 and 50.
+		validation.Field(&app.Limit, validation.Min(0), validation.Max(50)),
+	)
+}
+
+func (t *Tag) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case strings.Contains(err.Error(), "failed parse payload"):
+		status, response := responses.ErrorBadRequests(err.Error())
+		return c.Status(status).JSON(response)
+	default:
+		validationErrors, ok := err.(validation.Errors)
+		if !ok {
+			status, response := responses.ErrorServer(err.Error())
+			return c.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorBadRequests(validationMessage(validationErrors))
+		return c.Status(status).JSON(response)
+	}
+}
+
+// GetTags autocompletes tags, the most used first.
+func (t *Tag) GetTags(c *fiber.Ctx) error {
+	var filter QueryFilterGetTags
+	if err := c.QueryParser(&filter); err != nil {
+		return t.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	err := filter.Validate()
+	if err != nil {
+		return t.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 10
+	}
+
+	popular, err := t.Database.PopularTags(c.UserContext(), tags.Clean(filter.Prefix), filter.Limit)
+	if err != nil {
+		return t.handleError(c, err)
+	}
+
+	result := []TagResponse{}
+	for _, tag := range popular {
+		result = append(result, TagResponse{
+			Tag:   tag.Value,
+			Count: tag.Count,
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    result,
+	})
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"shopifyx/api/responses"
+	"shopifyx/db/entity"
+	"shopifyx/db/functions"
+	"shopifyx/internal/money"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+var voucherCodeRegexp = regexp.MustCompile(`^[A-Za-z0-9]+(-[A-Za-z0-9]+)*$`)
+
+const (
+	VoucherScopeSeller   = "seller"
+	VoucherScopePlatform = "platform"
+)
+
+type (
+	Voucher struct {
+		Database     *functions.Voucher
+		UserDatabase *functions.User
+	}
+
+	QueryFilterGetVouchers struct {
+		Scope string `json:"scope"`
+	}
+
+	VoucherPayload struct {
+		Code         string     `json:"code"`
+		Scope        string     `json:"scope"`
+		Type         string     `json:"type"`
+		Value        int64      `json:"value"`
+		MaxDiscount  int64      `json:"maxDiscount"`
+		MinSpend     int64      `json:"minSpend"`
+		Currency     string     `json:"currency"`
+		UsageLimit   int        `json:"usageLimit"`
+		PerUserLimit int        `json:"perUserLimit"`
+		StartsAt     *time.Time `json:"startsAt"`
+		EndsAt       *time.Time `json:"endsAt"`
+	}
+
+	VoucherResponse struct {
+		VoucherId    string     `json:"voucherId"`
+		Code         string     `json:"code"`
+		Scope        string     `json:"scope"`
+		Type         string     `json:"type"`
+		Value        int64      `json:"value"`
+		MaxDiscount  int64      `json:"maxDiscount"`
+		MinSpend     int64      `json:"minSpend"`
+		Currency     string     `json:"currency"`
+		UsageLimit   int        `json:"usageLimit"`
+		PerUserLimit int        `json:"perUserLimit"`
+		UsedCount    int        `json:"usedCount"`
+		StartsAt     time.Time  `json:"startsAt"`
+		EndsAt       *time.Time `json:"endsAt,omitempty"`
+		IsActive     bool       `json:"isActive"`
+		CreatedAt    time.Time  `json:"createdAt"`
+	}
+)
+
+func (app QueryFilterGetVouchers) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Scope should be either "seller" or "platform".
+		validation.Field(&app.Scope, validation.In(VoucherScopeSeller, VoucherScopePlatform)),
+	)
+}
+
+func (app VoucherPayload) Validate() error {
+	valueRules := []validation.Rule{validation.Required, validation.Min(1)}
+	if app.Type == entity.VoucherPercent {
+		valueRules = append(valueRules, validation.Max(100))
+	}
+
+	endsAtRules := []validation.Rule{}
+	if app.StartsAt != nil {
+		endsAtRules = append(endsAtRules, validation.Min(*app.StartsAt).Exclusive())
+	}
+
+	return validation.ValidateStruct(&app,
+		// Code cannot be empty, and should be letters and digits joined by dashes.
+		validation.Field(&app.Code, validation.Required, validation.Length(3, 32), validation.Match(voucherCodeRegexp)),
+		// Scope should be either "seller" or "platform".
+		validation.Field(&app.Scope, validation.In(VoucherScopeSeller, VoucherScopePlatform)),
+		// Type cannot be empty, and should be either "percent" or "fixed".
+		validation.Field(&app.Type, validation.Required, validation.In(entity.VoucherPercent, entity.VoucherFixed)),
+		// Value cannot be empty, and a percentage should be at most 100.
+		validation.Field(&app.Value, valueRules...),
+		// MaxDiscount should be greater than 0.
+		validation.Field(&app.MaxDiscount, validation.Min(0)),
+		// MinSpend should be greater than 0.
+		validation.Field(&app.MinSpend, validation.Min(0)),
+		// Currency is optional, and should be an ISO 4217 code.
+		validation.Field(&app.Currency, validation.Match(currencyRegexp)),
+		// UsageLimit should be greater than 0.
+		validation.Field(&app.UsageLimit, validation.Min(0)),
+		// PerUserLimit should be greater than 0.
+		validation.Field(&app.PerUserLimit, validation.Min(0)),
+		// EndsAt is optional, and should be after StartsAt.
+		validation.Field(&app.EndsAt, endsAtRules...),
+	)
+}
+
+func (v *Voucher) convertVoucherEntityToResponse(voucher entity.Voucher) VoucherResponse {
+	scope := VoucherScopeSeller
+	if voucher.UserID == nil {
+		scope = VoucherScopePlatform
+	}
+
+	return VoucherResponse{
+		VoucherId:    strconv.Itoa(voucher.ID),
+		Code:         voucher.Code,
+		Scope:        scope,
+		Type:         voucher.Type,
+		Value:        voucher.Value,
+		MaxDiscount:  voucher.MaxDiscount,
+		MinSpend:     voucher.MinSpend,
+		Currency:     voucher.Currency,
+		UsageLimit:   voucher.UsageLimit,
+		PerUserLimit: voucher.PerUserLimit,
+		UsedCount:    voucher.UsedCount,
+		StartsAt:     voucher.StartsAt,
+		EndsAt:       voucher.EndsAt,
+		IsActive:     voucher.IsActive,
+		CreatedAt:    voucher.CreatedAt,
+	}
+}
+
+func (v *Voucher) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, functions.ErrVoucherCodeDuplicate),
+		strings.Contains(err.Error(), "failed parse payload"),
+		strings.Contains(err.Error(), "failed parse voucher id"):
+		status, response := responses.ErrorBadRequests(err.Error())
+		return c.Status(status).JSON(response)
+	case errors.Is(err, fiber.ErrUnauthorized):
+		return fiber.ErrUnauthorized
+	case errors.Is(err, fiber.ErrForbidden):
+		return fiber.ErrForbidden
+	case errors.Is(err, functions.ErrVoucherNotFound):
+		status, response := responses.ErrorNotFound(err.Error())
+		return c.Status(status).JSON(response)
+	default:
+		validationErrors, ok := err.(validation.Errors)
+		if !ok {
+			status, response := responses.ErrorServer(err.Error())
+			return c.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorBadRequests(validationMessage(validationErrors))
+		return c.Status(status).JSON(response)
+	}
+}
+
+// voucherOwner returns the owner of vouchers in scope, the caller for seller
+// vouchers and nil for platform vouchers which only admins manage.
+func (v *Voucher) voucherOwner(c *fiber.Ctx, scope string) (*int, error) {
+	user, err := v.UserDatabase.GetUserById(c.UserContext(), c.Locals("user_id").(string))
+	if err != nil {
+		return nil, fiber.ErrUnauthorized
+	}
+
+	if scope == VoucherScopePlatform {
+		if !user.IsAdmin {
+			return nil, fiber.ErrForbidden
+		}
+		return nil, nil
+	}
+
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error()))
+	}
+
+	return &userID, nil
+}
+
+func (v *Voucher) GetVouchers(c *fiber.Ctx) error {
+	var filter QueryFilterGetVouchers
+	if err := c.QueryParser(&filter); err != nil {
+		return v.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	err := filter.Validate()
+	if err != nil {
+		return v.handleError(c, err)
+	}
+
+	owner, err := v.voucherOwner(c, filter.Scope)
+	if err != nil {
+		return v.handleError(c, err)
+	}
+
+	vouchers, err := v.Database.FindByOwner(c.UserContext(), owner)
+	if err != nil {
+		return v.handleError(c, err)
+	}
+
+	result := []VoucherResponse{}
+	for _, voucher := range vouchers {
+		result = append(result, v.convertVoucherEntityToResponse(voucher))
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    result,
+	})
+}
+
+func (v *Voucher) AddVoucher(c *fiber.Ctx) error {
+	var payload VoucherPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err := payload.Validate()
+	if err != nil {
+		return v.handleError(c, err)
+	}
+
+	owner, err := v.voucherOwner(c, payload.Scope)
+	if err != nil {
+		return v.handleError(c, err)
+	}
+
+	currency := payload.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+
+	startsAt := time.Now()
+	if payload.StartsAt != nil {
+		startsAt = *payload.StartsAt
+	}
+
+	voucher, err := v.Database.Add(c.UserContext(), entity.Voucher{
+		Code:         payload.Code,
+		UserID:       owner,
+		Type:         payload.Type,
+		Value:        payload.Value,
+		MaxDiscount:  payload.MaxDiscount,
+		MinSpend:     payload.MinSpend,
+		Currency:     currency,
+		UsageLimit:   payload.UsageLimit,
+		PerUserLimit: payload.PerUserLimit,
+		StartsAt:     startsAt,
+		EndsAt:       payload.EndsAt,
+	})
+	if err != nil {
+		return v.handleError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(map[string]interface{}{
+		"message": "voucher created successfully",
+		"data":    v.convertVoucherEntityToResponse(voucher),
+	})
+}
+
+// DeactivateVoucher ends a voucher early, payments that used it keep their
+// discount.
+func (v *Voucher) DeactivateVoucher(c *fiber.Ctx) error {
+	voucherID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return v.handleError(c, errors.New("failed parse voucher id"))
+	}
+
+	var filter QueryFilterGetVouchers
+	if err := c.QueryParser(&filter); err != nil {
+		return v.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return v.handleError(c, err)
+	}
+
+	owner, err := v.voucherOwner(c, filter.Scope)
+	if err != nil {
+		return v.handleError(c, err)
+	}
+
+	err = v.Database.Deactivate(c.UserContext(), voucherID, owner)
+	if err != nil {
+		return v.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "voucher deactivated successfully",
+	})
+}
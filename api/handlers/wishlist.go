@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopifyx/db/entity"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	WishlistItemResponse struct {
+		ProductResponse
+		AddedAt time.Time `json:"addedAt"`
+		// OutOfStock and NotPurchaseable flag saved products that cannot be
+		// bought right now.
+		OutOfStock      bool `json:"outOfStock"`
+		NotPurchaseable bool `json:"notPurchaseable"`
+	}
+)
+
+// convertWishlistItemEntityToResponse flags saved products that cannot be
+// bought, products of other sellers keep their stock settings and schedule
+// private.
+func (p *Product) convertWishlistItemEntityToResponse(item entity.WishlistItem, userID int) WishlistItemResponse {
+	product := p.convertProductEntityToResponse(item.Product)
+	if item.Product.UserID != userID {
+		product.HeldStock = 0
+		product.LowStockThreshold = 0
+		product.PublishAt = nil
+	}
+
+	return WishlistItemResponse{
+		ProductResponse: product,
+		AddedAt:         item.AddedAt,
+		OutOfStock:      product.Stock == 0,
+		NotPurchaseable: !item.Product.IsPurchaseable || item.Product.Status != entity.ProductStatusPublished,
+	}
+}
+
+// markWishlisted sets the inWishlist flag of products saved by the caller.
+func (p *Product) markWishlisted(c *fiber.Ctx, userID int, products []ProductResponse) error {
+	productIDs := []int{}
+	for _, product := range products {
+		id, _ := strconv.Atoi(product.ProductId)
+		productIDs = append(productIDs, id)
+	}
+
+	saved, err := p.WishlistDatabase.Contains(c.UserContext(), userID, productIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range products {
+		id, _ := strconv.Atoi(products[i].ProductId)
+		inWishlist := saved[id]
+		products[i].InWishlist = &inWishlist
+	}
+
+	return nil
+}
+
+func (p *Product) GetWishlist(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	var filter QueryFilterGetHistory
+	if err := c.QueryParser(&filter); err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse payload: %v", err.Error())))
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	items, total, err := p.WishlistDatabase.FindByUserID(c.UserContext(), userID, filter.Limit, filter.Offset)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	products := []ProductResponse{}
+	for _, item := range items {
+		products = append(products, p.convertProductEntityToResponse(item.Product))
+	}
+
+	err = p.convertDisplayPrices(c, c.Query("currency"), products)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	result := []WishlistItemResponse{}
+	for i, item := range items {
+		response := p.convertWishlistItemEntityToResponse(item, userID)
+		response.DisplayPrice = products[i].DisplayPrice
+		result = append(result, response)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "ok",
+		"data":    result,
+		"meta": Meta{
+			Limit:  filter.Limit,
+			Offset: filter.Offset,
+			Total:  total,
+		},
+	})
+}
+
+func (p *Product) AddToWishlist(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	productID, err := strconv.Atoi(c.Params("productId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse product id"))
+	}
+
+	err = p.WishlistDatabase.Add(c.UserContext(), userID, productID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "product added to wishlist",
+	})
+}
+
+func (p *Product) RemoveFromWishlist(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Locals("user_id").(string))
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	productID, err := strconv.Atoi(c.Params("productId"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse product id"))
+	}
+
+	err = p.WishlistDatabase.Remove(c.UserContext(), userID, productID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "product removed from wishlist",
+	})
+}
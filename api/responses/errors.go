@@ -55,3 +55,10 @@ func ErrorUnauthorized(m string) (int, map[string]interface{}) {
 		"message": m,
 	}
 }
+
+func ErrorPreconditionFailed(m string) (int, map[string]interface{}) {
+	return 412, map[string]interface{}{
+		"status":  "Error",
+		"message": m,
+	}
+}
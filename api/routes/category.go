@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"shopifyx/api/handlers"
+	"shopifyx/api/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func CategoryRoutes(app *fiber.App, h handlers.Category) {
+	g := app.Group("/v1/category")
+	g.Get("", h.GetCategories)
+	g.Post("", middleware.JWTAuth(), h.AddCategory)
+	g.Patch("/:id", middleware.JWTAuth(), h.UpdateCategory)
+	g.Delete("/:id", middleware.JWTAuth(), h.DeleteCategory)
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"shopifyx/api/handlers"
 	"shopifyx/db/functions"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -19,12 +20,26 @@ func RouteRegister(app *fiber.App, deps handlers.Dependencies) {
 	UserRoutes(app, userHandler)
 
 	productHandler := handlers.Product{
-		Database:     functions.NewProductFn(deps.DbPool),
-		UserDatabase: functions.NewUser(deps.DbPool, deps.Cfg),
-		BankDatabase: functions.NewBank(deps.DbPool),
+		Database:            functions.NewProductFn(deps.DbPool),
+		VariantDatabase:     functions.NewProductVariantFn(deps.DbPool),
+		ImageDatabase:       functions.NewProductImageFn(deps.DbPool),
+		HistoryDatabase:     functions.NewProductRevisionFn(deps.DbPool),
+		ReservationDatabase: functions.NewStockReservationFn(deps.DbPool),
+		MovementDatabase:    functions.NewStockMovementFn(deps.DbPool),
+		ReviewDatabase:      functions.NewProductReviewFn(deps.DbPool),
+		QuestionDatabase:    functions.NewProductQuestionFn(deps.DbPool),
+		WishlistDatabase:    functions.NewWishlistFn(deps.DbPool),
+		UserDatabase:        functions.NewUser(deps.DbPool, deps.Cfg),
+		BankDatabase:        functions.NewBank(deps.DbPool),
+		Retention:           time.Duration(deps.Cfg.ProductRetentionDays) * 24 * time.Hour,
+		ReservationTTL:      time.Duration(deps.Cfg.StockReservationMinutes) * time.Minute,
+		ReservationMaxQty:   deps.Cfg.StockReservationMaxQty,
+		TagAliases:          deps.Cfg.TagAliases,
+		Rates:               deps.Rates,
 	}
 
 	ProductRoutes(app, productHandler)
+	WishlistRoutes(app, productHandler)
 
 	imageUploaderHandler := handlers.ImageUploader{
 		Uploader: functions.NewImageUploader(deps.Cfg),
@@ -37,4 +52,30 @@ func RouteRegister(app *fiber.App, deps handlers.Dependencies) {
 	}
 
 	BankRoutes(app, bankAccountHandler)
+
+	categoryHandler := handlers.Category{
+		Database:     functions.NewCategory(deps.DbPool),
+		UserDatabase: functions.NewUser(deps.DbPool, deps.Cfg),
+	}
+
+	CategoryRoutes(app, categoryHandler)
+
+	notificationHandler := handlers.Notification{
+		Database: functions.NewNotificationFn(deps.DbPool),
+	}
+
+	NotificationRoutes(app, notificationHandler)
+
+	tagHandler := handlers.Tag{
+		Database: functions.NewProductFn(deps.DbPool),
+	}
+
+	TagRoutes(app, tagHandler)
+
+	voucherHandler := handlers.Voucher{
+		Database:     functions.NewVoucherFn(deps.DbPool),
+		UserDatabase: functions.NewUser(deps.DbPool, deps.Cfg),
+	}
+
+	VoucherRoutes(app, voucherHandler)
 }
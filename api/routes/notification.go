@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"shopifyx/api/handlers"
+	"shopifyx/api/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func NotificationRoutes(app *fiber.App, h handlers.Notification) {
+	g := app.Group("/v1/notification", middleware.JWTAuth())
+	g.Get("", h.GetNotifications)
+	g.Post("/:id/read", h.MarkNotificationRead)
+}
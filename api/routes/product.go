@@ -10,10 +10,38 @@ import (
 func ProductRoutes(app *fiber.App, h handlers.Product) {
 	g := app.Group("/v1/product")
 	g.Get("", middleware.OptionalJWTAuth(), h.GetProducts)
-	g.Get("/:id", h.GetProductDetail)
+	g.Get("/deleted", middleware.JWTAuth(), h.GetDeletedProducts)
+	g.Get("/export", middleware.JWTAuth(), h.ExportProducts)
+	g.Get("/:id", middleware.OptionalJWTAuth(), h.GetProductDetail)
+	g.Get("/:id/related", middleware.OptionalJWTAuth(), h.GetRelatedProducts)
 	g.Post("/:id/buy", middleware.JWTAuth(), h.BuyProduct)
+	g.Post("/:id/reservations", middleware.JWTAuth(), h.ReserveStock)
+	g.Delete("/:id/reservations/:reservationId", middleware.JWTAuth(), h.ReleaseReservation)
 	g.Post("/:id/stock", middleware.JWTAuth(), h.UpdateStock)
+	g.Post("/:id/stock/adjust", middleware.JWTAuth(), h.AdjustStock)
+	g.Get("/:id/stock/movements", middleware.JWTAuth(), h.GetStockMovements)
 	g.Post("", middleware.JWTAuth(), h.AddProduct)
+	g.Post("/import", middleware.JWTAuth(), h.ImportProducts)
 	g.Patch("/:id", middleware.JWTAuth(), h.UpdateProduct)
 	g.Delete("/:id", middleware.JWTAuth(), h.DeleteProduct)
+	g.Post("/:id/restore", middleware.JWTAuth(), h.RestoreProduct)
+	g.Get("/:id/history", middleware.JWTAuth(), h.GetHistory)
+	g.Get("/:id/variants", middleware.OptionalJWTAuth(), h.GetVariants)
+	g.Post("/:id/variants", middleware.JWTAuth(), h.AddVariant)
+	g.Patch("/:id/variants/:variantId", middleware.JWTAuth(), h.UpdateVariant)
+	g.Delete("/:id/variants/:variantId", middleware.JWTAuth(), h.DeleteVariant)
+	g.Get("/:id/reviews", middleware.OptionalJWTAuth(), h.GetReviews)
+	g.Post("/:id/reviews", middleware.JWTAuth(), h.AddReview)
+	g.Post("/:id/reviews/:reviewId/reply", middleware.JWTAuth(), h.ReplyReview)
+	g.Post("/:id/reviews/:reviewId/flag", middleware.JWTAuth(), h.FlagReview)
+	g.Patch("/:id/reviews/:reviewId/moderation", middleware.JWTAuth(), h.ModerateReview)
+	g.Get("/:id/questions", middleware.OptionalJWTAuth(), h.GetQuestions)
+	g.Post("/:id/questions", middleware.JWTAuth(), h.AskQuestion)
+	g.Post("/:id/questions/:questionId/answer", middleware.JWTAuth(), h.AnswerQuestion)
+	g.Patch("/:id/questions/:questionId/moderation", middleware.JWTAuth(), h.ModerateQuestion)
+	g.Get("/:id/images", middleware.OptionalJWTAuth(), h.GetImages)
+	g.Post("/:id/images", middleware.JWTAuth(), h.AddImage)
+	g.Put("/:id/images/order", middleware.JWTAuth(), h.ReorderImages)
+	g.Post("/:id/images/:imageId/cover", middleware.JWTAuth(), h.SetCoverImage)
+	g.Delete("/:id/images/:imageId", middleware.JWTAuth(), h.DeleteImage)
 }
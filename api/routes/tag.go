@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"shopifyx/api/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TagRoutes(app *fiber.App, h handlers.Tag) {
+	g := app.Group("/v1/tags")
+	g.Get("", h.GetTags)
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"shopifyx/api/handlers"
+	"shopifyx/api/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func VoucherRoutes(app *fiber.App, h handlers.Voucher) {
+	g := app.Group("/v1/voucher", middleware.JWTAuth())
+	g.Get("", h.GetVouchers)
+	g.Post("", h.AddVoucher)
+	g.Delete("/:id", h.DeactivateVoucher)
+}
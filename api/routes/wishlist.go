@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"shopifyx/api/handlers"
+	"shopifyx/api/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func WishlistRoutes(app *fiber.App, h handlers.Product) {
+	g := app.Group("/v1/wishlist", middleware.JWTAuth())
+	g.Get("", h.GetWishlist)
+	g.Post("/:productId", h.AddToWishlist)
+	g.Delete("/:productId", h.RemoveFromWishlist)
+}
@@ -3,12 +3,17 @@ package webservices
 import (
 	"context"
 	"log"
+	"time"
 
 	"shopifyx/api/handlers"
 	"shopifyx/api/responses"
 	"shopifyx/api/routes"
 	"shopifyx/configs"
 	"shopifyx/db/connections"
+	"shopifyx/db/functions"
+	"shopifyx/internal/jobs"
+	"shopifyx/internal/money"
+	"shopifyx/internal/notifier"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -39,14 +44,35 @@ func Run() {
 		DbPool: dbPool,
 	}
 
+	if config.RatesFile != "" {
+		rates, err := money.LoadStaticRates(config.RatesFile)
+		if err != nil {
+			log.Fatalf("failed load exchange rates: %v", err)
+		}
+		deps.Rates = rates
+	}
+
 	// load Middlewares
 	app.Use(recover.New())
 	app.Use(logger.New())
-	app.Use(cors.New())
+	app.Use(cors.New(cors.Config{
+		ExposeHeaders: fiber.HeaderETag,
+	}))
 
 	// register route in another package
 	routes.RouteRegister(app, deps)
 
+	// background jobs
+	retention := time.Duration(config.ProductRetentionDays) * 24 * time.Hour
+	go jobs.Every(context.Background(), "purge deleted products", time.Hour,
+		jobs.PurgeDeletedProducts(functions.NewProductFn(dbPool), retention))
+	go jobs.Every(context.Background(), "publish scheduled products", time.Minute,
+		jobs.PublishScheduledProducts(functions.NewProductFn(dbPool)))
+	go jobs.Every(context.Background(), "release expired stock reservations", time.Minute,
+		jobs.ReleaseExpiredReservations(functions.NewStockReservationFn(dbPool)))
+	go jobs.Every(context.Background(), "deliver notifications", 30*time.Second,
+		jobs.DeliverNotifications(functions.NewNotificationFn(dbPool), notifier.Log{}))
+
 	// handle unavailable route
 	app.Use(func(c *fiber.Ctx) error {
 		return responses.ReturnTheResponse(c, true, int(404), "Not Found", nil)
@@ -3,6 +3,7 @@ package configs
 import (
 	"fmt"
 	"os"
+	"shopifyx/internal/tags"
 	"strconv"
 )
 
@@ -24,6 +25,20 @@ type Config struct {
 	S3ID        string
 	S3SecretKey string
 	S3BaseURL   string
+
+	// ProductRetentionDays is how long deleted products can be restored before
+	// they are purged.
+	ProductRetentionDays int
+	// StockReservationMinutes is how long a reservation holds stock.
+	StockReservationMinutes int
+	// StockReservationMaxQty is the quantity of a product a buyer can hold
+	// across their active reservations.
+	StockReservationMaxQty int
+	// TagAliases rewrites product tags on write, e.g. "sepatu" to "shoes".
+	TagAliases tags.Aliases
+	// RatesFile is the JSON file of exchange rates used to display prices in
+	// another currency, conversion is disabled when empty.
+	RatesFile string
 }
 
 func LoadConfig() (Config, error) {
@@ -44,6 +59,8 @@ func LoadConfig() (Config, error) {
 		S3ID:        os.Getenv("S3_ID"),
 		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
 		S3BaseURL:   os.Getenv("S3_BASE_URL"),
+
+		RatesFile: os.Getenv("RATES_FILE"),
 	}
 
 	salt, err := strconv.Atoi(os.Getenv("BCRYPT_SALT"))
@@ -57,5 +74,34 @@ func LoadConfig() (Config, error) {
 
 	config.BcryptSalt = salt
 
+	config.ProductRetentionDays = 30
+	if os.Getenv("PRODUCT_RETENTION_DAYS") != "" {
+		config.ProductRetentionDays, err = strconv.Atoi(os.Getenv("PRODUCT_RETENTION_DAYS"))
+		if err != nil {
+			return Config{}, fmt.Errorf("failed get product retention days %v", err)
+		}
+	}
+
+	config.StockReservationMinutes = 15
+	if os.Getenv("STOCK_RESERVATION_MINUTES") != "" {
+		config.StockReservationMinutes, err = strconv.Atoi(os.Getenv("STOCK_RESERVATION_MINUTES"))
+		if err != nil {
+			return Config{}, fmt.Errorf("failed get stock reservation minutes %v", err)
+		}
+	}
+
+	config.StockReservationMaxQty = 10
+	if os.Getenv("STOCK_RESERVATION_MAX_QTY") != "" {
+		config.StockReservationMaxQty, err = strconv.Atoi(os.Getenv("STOCK_RESERVATION_MAX_QTY"))
+		if err != nil {
+			return Config{}, fmt.Errorf("failed get stock reservation max qty %v", err)
+		}
+	}
+
+	config.TagAliases, err = tags.ParseAliases(os.Getenv("TAG_ALIASES"))
+	if err != nil {
+		return Config{}, fmt.Errorf("failed get tag aliases %v", err)
+	}
+
 	return config, nil
 }
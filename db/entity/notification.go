@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+const (
+	NotificationLowStock = "low_stock"
+	NotificationQuestion = "question"
+	NotificationAnswer   = "answer"
+)
+
+type Notification struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Type        string     `json:"type"`
+	ProductID   *int       `json:"product_id"`
+	Message     string     `json:"message"`
+	ReadAt      *time.Time `json:"read_at"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -1,13 +1,18 @@
 package entity
 
-import "time"
+import (
+	"shopifyx/internal/money"
+	"time"
+)
 
 type ProductPayment struct {
-	Id       int
-	Name     string
-	ImageUrl string
-	Price    int
-	Qty      int
+	Id         int
+	Name       string
+	ImageUrl   string
+	SellerId   int
+	Price      money.Money
+	Qty        int
+	VariantSKU *string
 }
 
 type UserPayment struct {
@@ -24,11 +29,18 @@ type BankPayment struct {
 }
 
 type Payment struct {
-	Id                   string    `json:"id"`
-	ProductId            int       `json:"productId"`
-	BankAccountId        int       `json:"bankAccountId"`
-	PaymentProofImageUrl string    `json:"paymentProofImageUrl"`
-	Qty                  int       `json:"quantity"`
-	CreatedAt            time.Time `json:"createdAt"`
-	UpdatedAt            time.Time `json:"updatedAt"`
+	Id                   string      `json:"id"`
+	ProductId            int         `json:"productId"`
+	VariantId            int         `json:"variantId,omitempty"`
+	ReservationId        int         `json:"reservationId,omitempty"`
+	BuyerId              int         `json:"-"`
+	BankAccountId        int         `json:"bankAccountId"`
+	PaymentProofImageUrl string      `json:"paymentProofImageUrl"`
+	Qty                  int         `json:"quantity"`
+	VoucherCode          string      `json:"voucherCode,omitempty"`
+	Subtotal             money.Money `json:"subtotal"`
+	Discount             money.Money `json:"discount"`
+	TotalAmount          money.Money `json:"totalAmount"`
+	CreatedAt            time.Time   `json:"createdAt"`
+	UpdatedAt            time.Time   `json:"updatedAt"`
 }
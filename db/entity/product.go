@@ -1,17 +1,58 @@
 package entity
 
+import (
+	"shopifyx/internal/money"
+	"time"
+)
+
+const (
+	ProductStatusDraft     = "draft"
+	ProductStatusScheduled = "scheduled"
+	ProductStatusPublished = "published"
+	ProductStatusArchived  = "archived"
+)
+
 type (
 	Product struct {
-		ID             int      `json:"id"`
-		UserID         int      `json:"user_id"`
-		Name           string   `json:"name"`
-		Price          int      `json:"price"`
-		ImageUrl       string   `json:"image_url"`
-		Stock          int      `json:"stock"`
-		Condition      string   `json:"condition"`
-		Tags           []string `json:"tags"`
-		IsPurchaseable bool     `json:"is_purchaseable"`
-		PurchaseCount  int      `json:"purchase_count"`
+		ID             int         `json:"id"`
+		UserID         int         `json:"user_id"`
+		Name           string      `json:"name"`
+		Price          money.Money `json:"price"`
+		ImageUrl       string      `json:"image_url"`
+		Stock          int         `json:"stock"`
+		Condition      string      `json:"condition"`
+		Tags           []string    `json:"tags"`
+		IsPurchaseable bool        `json:"is_purchaseable"`
+		PurchaseCount  int         `json:"purchase_count"`
+		CategoryID     *int        `json:"category_id"`
+		CreatedAt      time.Time   `json:"created_at"`
+		UpdatedAt      time.Time   `json:"updated_at"`
+		DeletedAt      *time.Time  `json:"deleted_at"`
+		// Status is one of the ProductStatus constants, an empty status is
+		// stored as published on insert and left unchanged on update.
+		Status    string     `json:"status"`
+		PublishAt *time.Time `json:"publish_at"`
+		// Version is bumped on every change. Writes given a non-zero Version
+		// fail when the product has moved past it.
+		Version int `json:"version"`
+		// HeldStock is the part of Stock held by active reservations.
+		HeldStock int `json:"held_stock"`
+		// LowStockThreshold is the stock at which the seller is notified, 0
+		// disables the notification.
+		LowStockThreshold int `json:"low_stock_threshold"`
+		// SalePrice, in the currency of Price, replaces Price between
+		// SaleStartsAt and SaleEndsAt. EffectivePrice is the price in force
+		// when the product was read.
+		SalePrice      *int64      `json:"sale_price"`
+		SaleStartsAt   *time.Time  `json:"sale_starts_at"`
+		SaleEndsAt     *time.Time  `json:"sale_ends_at"`
+		EffectivePrice money.Money `json:"effective_price"`
+		// Rating is the average rating of the visible reviews.
+		Rating      float32 `json:"rating"`
+		ReviewCount int     `json:"review_count"`
+		// Relevance and Highlight are only filled when listing with a search.
+		Relevance float32 `json:"relevance"`
+		Highlight string  `json:"highlight"`
 	}
 
 	FilterGetProducts struct {
@@ -26,5 +67,24 @@ type (
 		SortBy         string   `json:"sortBy"`
 		OrderBy        string   `json:"orderBy"`
 		Search         string   `json:"search"`
+		// Category is a category slug, products of its descendants match too.
+		Category string `json:"category"`
+		// LowStock only keeps the products of the user at or below their low
+		// stock threshold.
+		LowStock bool `json:"lowStock"`
+		// PriceCurrency only keeps the products priced in it, prices of
+		// different currencies cannot be compared.
+		PriceCurrency string `json:"priceCurrency"`
+		// Cursor switches FindAll to keyset pagination, Offset is ignored when set.
+		Cursor *ProductCursor `json:"cursor"`
+	}
+
+	// ProductCursor is the position of a product within a sorted listing.
+	ProductCursor struct {
+		SortBy   string `json:"s,omitempty"`
+		OrderBy  string `json:"o,omitempty"`
+		Key      string `json:"k,omitempty"`
+		ID       int    `json:"i"`
+		Backward bool   `json:"b,omitempty"`
 	}
 )
@@ -0,0 +1,20 @@
+package entity
+
+type (
+	FacetCount struct {
+		Value string `json:"value"`
+		Count int    `json:"count"`
+	}
+
+	PriceBucket struct {
+		Min   int64 `json:"min"`
+		Max   int64 `json:"max"`
+		Count int   `json:"count"`
+	}
+
+	ProductFacets struct {
+		Tags       []FacetCount  `json:"tags"`
+		Conditions []FacetCount  `json:"conditions"`
+		Prices     []PriceBucket `json:"prices"`
+	}
+)
@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+type ProductImage struct {
+	ID        int       `json:"id"`
+	ProductID int       `json:"product_id"`
+	ImageUrl  string    `json:"image_url"`
+	Position  int       `json:"position"`
+	IsCover   bool      `json:"is_cover"`
+	CreatedAt time.Time `json:"created_at"`
+}
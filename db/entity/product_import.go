@@ -0,0 +1,13 @@
+package entity
+
+const (
+	ProductImportCreated = "created"
+	ProductImportUpdated = "updated"
+	ProductImportFailed  = "failed"
+)
+
+type ProductImportResult struct {
+	ProductID int    `json:"product_id"`
+	Action    string `json:"action"`
+	Error     string `json:"error"`
+}
@@ -0,0 +1,15 @@
+package entity
+
+import "time"
+
+type ProductQuestion struct {
+	ID         int        `json:"id"`
+	ProductID  int        `json:"product_id"`
+	UserID     int        `json:"user_id"`
+	Question   string     `json:"question"`
+	Answer     *string    `json:"answer"`
+	AnsweredAt *time.Time `json:"answered_at"`
+	IsHidden   bool       `json:"is_hidden"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+type ProductReview struct {
+	ID        int        `json:"id"`
+	ProductID int        `json:"product_id"`
+	UserID    int        `json:"user_id"`
+	Rating    int        `json:"rating"`
+	Body      string     `json:"body"`
+	Images    []string   `json:"images"`
+	Reply     *string    `json:"reply"`
+	RepliedAt *time.Time `json:"replied_at"`
+	FlagCount int        `json:"flag_count"`
+	IsHidden  bool       `json:"is_hidden"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
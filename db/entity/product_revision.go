@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ProductRevisionCreate   = "create"
+	ProductRevisionUpdate   = "update"
+	ProductRevisionStock    = "stock"
+	ProductRevisionDelete   = "delete"
+	ProductRevisionRestore  = "restore"
+	ProductRevisionPurchase = "purchase"
+	ProductRevisionVariant  = "variant"
+	ProductRevisionImage    = "image"
+	ProductRevisionPublish  = "publish"
+)
+
+type ProductRevision struct {
+	ID        int             `json:"id"`
+	ProductID int             `json:"product_id"`
+	UserID    *int            `json:"user_id"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+type ProductVariant struct {
+	ID        int               `json:"id"`
+	ProductID int               `json:"product_id"`
+	SKU       string            `json:"sku"`
+	Options   map[string]string `json:"options"`
+	// Price is in the currency of the product.
+	Price         int64     `json:"price"`
+	Stock         int       `json:"stock"`
+	ImageUrl      string    `json:"image_url"`
+	PurchaseCount int       `json:"purchase_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	HeldStock     int       `json:"held_stock"`
+}
@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+const (
+	StockMovementSale       = "sale"
+	StockMovementAdjustment = "adjustment"
+	StockMovementRestock    = "restock"
+	StockMovementReturn     = "return"
+	StockMovementCorrection = "correction"
+)
+
+type StockMovement struct {
+	ID         int       `json:"id"`
+	ProductID  int       `json:"product_id"`
+	VariantID  *int      `json:"variant_id"`
+	UserID     *int      `json:"user_id"`
+	Type       string    `json:"type"`
+	Delta      int       `json:"delta"`
+	StockAfter int       `json:"stock_after"`
+	Reason     string    `json:"reason"`
+	PaymentID  *int      `json:"payment_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+const (
+	StockReservationActive   = "active"
+	StockReservationConsumed = "consumed"
+	StockReservationReleased = "released"
+)
+
+type StockReservation struct {
+	ID        int       `json:"id"`
+	ProductID int       `json:"product_id"`
+	VariantID *int      `json:"variant_id"`
+	UserID    int       `json:"user_id"`
+	Qty       int       `json:"qty"`
+	Status    string    `json:"status"`
+	PaymentID *int      `json:"payment_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -5,4 +5,5 @@ type User struct {
 	Name     string `json:"name"`
 	Username string `json:"username"`
 	Password string `json:"password,omitempty"`
+	IsAdmin  bool   `json:"is_admin"`
 }
@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+const (
+	VoucherPercent = "percent"
+	VoucherFixed   = "fixed"
+)
+
+type Voucher struct {
+	ID   int    `json:"id"`
+	Code string `json:"code"`
+	// UserID is the seller the voucher is scoped to, nil for the platform.
+	UserID *int   `json:"user_id"`
+	Type   string `json:"type"`
+	// Value is a percentage, or an amount in Currency like MaxDiscount and
+	// MinSpend.
+	Value        int64      `json:"value"`
+	MaxDiscount  int64      `json:"max_discount"`
+	MinSpend     int64      `json:"min_spend"`
+	Currency     string     `json:"currency"`
+	UsageLimit   int        `json:"usage_limit"`
+	PerUserLimit int        `json:"per_user_limit"`
+	UsedCount    int        `json:"used_count"`
+	StartsAt     time.Time  `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	IsActive     bool       `json:"is_active"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
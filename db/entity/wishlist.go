@@ -0,0 +1,8 @@
+package entity
+
+import "time"
+
+type WishlistItem struct {
+	Product Product   `json:"product"`
+	AddedAt time.Time `json:"added_at"`
+}
@@ -0,0 +1,161 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shopifyx/db/entity"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Category struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewCategory(dbPool *pgxpool.Pool) *Category {
+	return &Category{
+		dbPool: dbPool,
+	}
+}
+
+const categoryColumns = `id, parent_id, name, slug, created_at, updated_at`
+
+func scanCategory(row pgx.Row, category *entity.Category) error {
+	return row.Scan(&category.ID, &category.ParentID, &category.Name, &category.Slug, &category.CreatedAt, &category.UpdatedAt)
+}
+
+// categoryDescendantsQuery selects the id of the category with the bound slug
+// and the ids of all of its descendants.
+func categoryDescendantsQuery(slugPlaceholder string) string {
+	return `WITH RECURSIVE tree AS (
+		SELECT id FROM categories WHERE slug = ` + slugPlaceholder + `
+		UNION ALL
+		SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+	) SELECT id FROM tree`
+}
+
+func categoryError(err error) error {
+	switch {
+	case strings.Contains(err.Error(), "duplicate key value violates unique constraint"):
+		return ErrCategorySlugDuplicate
+	case strings.Contains(err.Error(), "violates foreign key constraint"):
+		return ErrCategoryNotFound
+	}
+
+	return err
+}
+
+func (ct *Category) FindAll(ctx context.Context) ([]entity.Category, error) {
+	conn, err := ct.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT `+categoryColumns+` FROM categories ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed get categories: %v", err)
+	}
+
+	defer rows.Close()
+
+	categories := []entity.Category{}
+
+	for rows.Next() {
+		category := entity.Category{}
+		err := scanCategory(rows, &category)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan categories: %v", err)
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, nil
+}
+
+func (ct *Category) Add(ctx context.Context, category entity.Category) (entity.Category, error) {
+	conn, err := ct.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.Category{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	err = scanCategory(conn.QueryRow(ctx, `
+		insert into categories (parent_id, name, slug) values ($1, $2, $3)
+		returning `+categoryColumns,
+		category.ParentID, category.Name, category.Slug), &category)
+	if err != nil {
+		return entity.Category{}, fmt.Errorf("failed insert category: %w", categoryError(err))
+	}
+
+	return category, nil
+}
+
+func (ct *Category) Update(ctx context.Context, category entity.Category) (entity.Category, error) {
+	conn, err := ct.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.Category{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	if category.ParentID != nil {
+		var isDescendant bool
+		err = conn.QueryRow(ctx, `
+			WITH RECURSIVE tree AS (
+				SELECT id FROM categories WHERE id = $1
+				UNION ALL
+				SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+			) SELECT exists (SELECT 1 FROM tree WHERE id = $2)
+		`, category.ID, *category.ParentID).Scan(&isDescendant)
+		if err != nil {
+			return entity.Category{}, fmt.Errorf("failed check category parent: %v", err)
+		}
+
+		if isDescendant {
+			return entity.Category{}, ErrCategoryCycle
+		}
+	}
+
+	err = scanCategory(conn.QueryRow(ctx, `
+		update categories set parent_id = $1, name = $2, slug = $3, updated_at = now()
+		where id = $4
+		returning `+categoryColumns,
+		category.ParentID, category.Name, category.Slug, category.ID), &category)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Category{}, ErrCategoryNotFound
+		}
+		return entity.Category{}, fmt.Errorf("failed update category: %w", categoryError(err))
+	}
+
+	return category, nil
+}
+
+func (ct *Category) Delete(ctx context.Context, categoryID int) error {
+	conn, err := ct.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `delete from categories where id = $1`, categoryID)
+	if err != nil {
+		if strings.Contains(err.Error(), "violates foreign key constraint") {
+			return ErrCategoryHasChildren
+		}
+		return fmt.Errorf("failed delete category: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrCategoryNotFound
+	}
+
+	return nil
+}
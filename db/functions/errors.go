@@ -3,8 +3,35 @@ package functions
 import "errors"
 
 var (
-	ErrNoRow                = errors.New("data not found")
-	ErrInsuficientQty       = errors.New("insuficient quantity")
-	ErrUnauthorized         = errors.New("unauthorized")
-	ErrProductNameDuplicate = errors.New("product name already exists")
+	ErrNoRow                 = errors.New("data not found")
+	ErrInsuficientQty        = errors.New("insuficient quantity")
+	ErrUnauthorized          = errors.New("unauthorized")
+	ErrProductNameDuplicate  = errors.New("product name already exists")
+	ErrInvalidSort           = errors.New("invalid sort parameter")
+	ErrInvalidCursor         = errors.New("invalid cursor")
+	ErrVariantRequired       = errors.New("product has variants, variant id is required")
+	ErrVariantSKUDuplicate   = errors.New("variant sku already exists")
+	ErrLastProductImage      = errors.New("product must keep at least one image")
+	ErrInvalidImageOrder     = errors.New("image order must list every product image once")
+	ErrCategoryNotFound      = errors.New("category not found")
+	ErrCategorySlugDuplicate = errors.New("category slug already exists")
+	ErrCategoryHasChildren   = errors.New("category still has child categories")
+	ErrCategoryCycle         = errors.New("category cannot be moved under itself")
+	ErrVersionMismatch       = errors.New("product was changed, version does not match")
+	ErrReservationNotFound   = errors.New("reservation not found or expired")
+	ErrReservationMismatch   = errors.New("purchase does not match the reservation")
+	ErrReservationLimit      = errors.New("reservation limit of the product reached")
+	ErrReserveOwnProduct     = errors.New("sellers cannot reserve their own product")
+	ErrNotPurchaseable       = errors.New("product is not purchaseable")
+	ErrNegativeStock         = errors.New("stock cannot go below zero")
+	ErrNotVerifiedBuyer      = errors.New("only buyers of the product can review it")
+	ErrReviewDuplicate       = errors.New("product already reviewed")
+	ErrReviewNotFound        = errors.New("review not found")
+	ErrVoucherNotFound       = errors.New("voucher not found")
+	ErrVoucherCodeDuplicate  = errors.New("voucher code already exists")
+	ErrVoucherInactive       = errors.New("voucher is not active")
+	ErrVoucherNotApplicable  = errors.New("voucher does not apply to this product")
+	ErrVoucherMinSpend       = errors.New("minimum spend of the voucher is not reached")
+	ErrVoucherUsedUp         = errors.New("voucher usage limit reached")
+	ErrQuestionNotFound      = errors.New("question not found")
 )
@@ -0,0 +1,153 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"shopifyx/db/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Notification struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewNotificationFn(dbPool *pgxpool.Pool) *Notification {
+	return &Notification{
+		dbPool: dbPool,
+	}
+}
+
+const notificationColumns = `id, user_id, type, product_id, message, read_at, delivered_at, created_at`
+
+func scanNotification(row pgx.Row, notification *entity.Notification) error {
+	return row.Scan(
+		&notification.ID, &notification.UserID, &notification.Type, &notification.ProductID, &notification.Message, &notification.ReadAt, &notification.DeliveredAt, &notification.CreatedAt,
+	)
+}
+
+func collectNotifications(rows pgx.Rows) ([]entity.Notification, error) {
+	defer rows.Close()
+
+	notifications := []entity.Notification{}
+	for rows.Next() {
+		notification := entity.Notification{}
+		if err := scanNotification(rows, &notification); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, rows.Err()
+}
+
+// notifyLowStock notifies the seller when a stock change of delta brought the
+// product down to its low stock threshold. It must run after the change.
+func notifyLowStock(ctx context.Context, tx pgx.Tx, productID, delta int) error {
+	_, err := tx.Exec(ctx, `
+		insert into notifications (user_id, type, product_id, message)
+		select user_id, $3, id, 'stock of ' || name || ' is down to ' || stock || ', the low stock threshold is ' || low_stock_threshold
+		from products
+		where id = $1 and low_stock_threshold > 0 and stock <= low_stock_threshold and stock - $2 > low_stock_threshold
+	`, productID, delta, entity.NotificationLowStock)
+	if err != nil {
+		return fmt.Errorf("failed notify low stock: %v", err)
+	}
+
+	return nil
+}
+
+// FindByUserID returns the notifications of a user, most recent first.
+func (n *Notification) FindByUserID(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]entity.Notification, int, error) {
+	conn, err := n.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	q := &queryBuilder{}
+	q.and("user_id = " + q.bind(userID))
+	if unreadOnly {
+		q.and("read_at IS NULL")
+	}
+
+	var total int
+	err = conn.QueryRow(ctx, `SELECT COUNT(id) FROM notifications`+q.where(), q.args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get notifications count: %v", err)
+	}
+
+	rows, err := conn.Query(ctx, `SELECT `+notificationColumns+` FROM notifications`+q.where()+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT `+q.bind(limit)+` OFFSET `+q.bind(offset), q.args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get notifications: %v", err)
+	}
+
+	notifications, err := collectNotifications(rows)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed scan notifications: %v", err)
+	}
+
+	return notifications, total, nil
+}
+
+func (n *Notification) MarkRead(ctx context.Context, notificationID, userID int) error {
+	conn, err := n.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `update notifications set read_at = coalesce(read_at, now()) where id = $1 and user_id = $2`, notificationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed mark notification read: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRow
+	}
+
+	return nil
+}
+
+// FindUndelivered returns the oldest notifications not sent by a notifier yet.
+func (n *Notification) FindUndelivered(ctx context.Context, limit int) ([]entity.Notification, error) {
+	conn, err := n.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT `+notificationColumns+` FROM notifications WHERE delivered_at IS NULL ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed get undelivered notifications: %v", err)
+	}
+
+	notifications, err := collectNotifications(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed scan undelivered notifications: %v", err)
+	}
+
+	return notifications, nil
+}
+
+func (n *Notification) MarkDelivered(ctx context.Context, notificationID int) error {
+	conn, err := n.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `update notifications set delivered_at = now() where id = $1`, notificationID)
+	if err != nil {
+		return fmt.Errorf("failed mark notification delivered: %v", err)
+	}
+
+	return nil
+}
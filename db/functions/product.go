@@ -5,7 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"shopifyx/db/entity"
+	"shopifyx/internal/money"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -21,44 +25,238 @@ func NewProductFn(dbPool *pgxpool.Pool) *Product {
 	}
 }
 
-func (p *Product) constructWhereQuery(ctx context.Context, filter entity.FilterGetProducts, userID int) string {
-	whereSQL := []string{}
+// productColumns is the column list scanned by scanProduct.
+var productColumns = `id, user_id, name, price, currency, image_url, stock, condition, tags, is_purchaseable, purchase_count, category_id, created_at, updated_at, deleted_at, status, publish_at, version, low_stock_threshold, ` +
+	heldStockSQL("product_id", "products.id") + `, ` + productRatingSQL + `, ` + productReviewCountSQL + `, sale_price, sale_starts_at, sale_ends_at, ` + effectivePriceSQL("now()") + `, currency`
+
+// effectivePriceSQL is the price in force at the time expression at, the sale
+// price while the sale runs and the price otherwise.
+func effectivePriceSQL(at string) string {
+	return `(CASE WHEN sale_price IS NOT NULL AND (sale_starts_at IS NULL OR sale_starts_at <= ` + at + `) AND (sale_ends_at IS NULL OR sale_ends_at > ` + at + `) THEN sale_price ELSE price END)`
+}
+
+func productFields(product *entity.Product) []interface{} {
+	return []interface{}{
+		&product.ID, &product.UserID, &product.Name, &product.Price.Amount, &product.Price.Currency, &product.ImageUrl, &product.Stock, &product.Condition, &product.Tags, &product.IsPurchaseable, &product.PurchaseCount, &product.CategoryID, &product.CreatedAt, &product.UpdatedAt, &product.DeletedAt, &product.Status, &product.PublishAt, &product.Version, &product.LowStockThreshold, &product.HeldStock, &product.Rating, &product.ReviewCount, &product.SalePrice, &product.SaleStartsAt, &product.SaleEndsAt, &product.EffectivePrice.Amount, &product.EffectivePrice.Currency,
+	}
+}
+
+func scanProduct(row pgx.Row, product *entity.Product) error {
+	return row.Scan(productFields(product)...)
+}
+
+// productTSQuery binds the search text and returns it as a tsquery expression.
+func productTSQuery(q *queryBuilder, search string) string {
+	return "websearch_to_tsquery('simple', " + q.bind(search) + ")"
+}
+
+type productSortColumn struct {
+	column func(q *queryBuilder, filter entity.FilterGetProducts) string
+	// cast is the SQL type a cursor key is converted to before comparison.
+	cast string
+	key  func(product entity.Product) string
+	// descending is the direction used when orderBy is not given.
+	descending bool
+}
+
+func staticColumn(column string) func(*queryBuilder, entity.FilterGetProducts) string {
+	return func(*queryBuilder, entity.FilterGetProducts) string {
+		return column
+	}
+}
+
+// productSortColumns whitelists the sortBy values accepted by FindAll.
+var productSortColumns = map[string]productSortColumn{
+	"price": {
+		column: staticColumn(effectivePriceSQL("now()")),
+		cast:   "bigint",
+		key: func(product entity.Product) string {
+			return strconv.FormatInt(product.EffectivePrice.Amount, 10)
+		},
+	},
+	"date": {
+		column: staticColumn("created_at"),
+		cast:   "timestamptz",
+		key: func(product entity.Product) string {
+			return product.CreatedAt.Format(time.RFC3339Nano)
+		},
+	},
+	"rating": {
+		column: staticColumn(productRatingSQL),
+		cast:   "real",
+		key: func(product entity.Product) string {
+			return strconv.FormatFloat(float64(product.Rating), 'g', -1, 32)
+		},
+		descending: true,
+	},
+	"relevance": {
+		column: func(q *queryBuilder, filter entity.FilterGetProducts) string {
+			return "ts_rank(search_vector, " + productTSQuery(q, filter.Search) + ")"
+		},
+		cast: "real",
+		key: func(product entity.Product) string {
+			return strconv.FormatFloat(float64(product.Relevance), 'g', -1, 32)
+		},
+		descending: true,
+	},
+}
+
+// productOrderDirections whitelists the orderBy values accepted by FindAll.
+var productOrderDirections = map[string]string{
+	"asc": "ASC",
+	"dsc": "DESC",
+}
+
+func (p *Product) constructWhereQuery(filter entity.FilterGetProducts, userID int) *queryBuilder {
+	q := &queryBuilder{}
+	q.and("deleted_at IS NULL")
+
 	if filter.UserOnly {
-		whereSQL = append(whereSQL, " user_id = "+fmt.Sprintf("%d", userID))
+		q.and("user_id = " + q.bind(userID))
+	} else {
+		// sellers still see their own unpublished products
+		q.and("(status = '" + entity.ProductStatusPublished + "' OR user_id = " + q.bind(userID) + ")")
 	}
 
-	if filter.Tags != nil && len(filter.Tags) > 0 {
-		tags := strings.Join(filter.Tags, "','")
-		whereSQL = append(whereSQL, " ARRAY['"+tags+"']::varchar[] <@ tags	")
+	if len(filter.Tags) > 0 {
+		q.and(q.bind(filter.Tags) + "::varchar[] <@ tags")
 	}
 
 	if filter.Condition != "" {
-		whereSQL = append(whereSQL, " condition = '"+filter.Condition+"'")
+		q.and("condition = " + q.bind(filter.Condition))
 	}
 
-	if !filter.ShowEmptyStock {
-		whereSQL = append(whereSQL, " stock > 0")
+	// low stock products are only listed to their seller, out of stock ones
+	// included
+	if filter.LowStock {
+		q.and("user_id = " + q.bind(userID))
+		q.and("low_stock_threshold > 0 AND stock <= low_stock_threshold")
 	}
 
+	// units held by reservations are not available
+	if !filter.ShowEmptyStock && !filter.LowStock {
+		q.and("stock > " + heldStockSQL("product_id", "products.id"))
+	}
+
+	if filter.PriceCurrency != "" {
+		q.and("currency = " + q.bind(filter.PriceCurrency))
+	}
+
+	// products with variants match when one of their variants is within the
+	// price range, and has stock unless empty stock is shown. Other products
+	// match on their effective price
+	prices := []string{}
 	if filter.MaxPrice > 0 {
-		whereSQL = append(whereSQL, " price <= "+fmt.Sprintf("%d", filter.MaxPrice))
+		prices = append(prices, "%[1]s <= "+q.bind(filter.MaxPrice))
 	}
 
 	if filter.MinPrice > 0 {
-		whereSQL = append(whereSQL, " price >= "+fmt.Sprintf("%d", filter.MinPrice))
+		prices = append(prices, "%[1]s >= "+q.bind(filter.MinPrice))
+	}
+
+	if len(prices) > 0 {
+		variantPrices := fmt.Sprintf(strings.Join(prices, " AND "), "v.price")
+		if !filter.ShowEmptyStock {
+			variantPrices += " AND v.stock > " + heldStockSQL("variant_id", "v.id")
+		}
+
+		q.and(fmt.Sprintf(
+			"(EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id AND %s)"+
+				" OR (NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id) AND %s))",
+			variantPrices,
+			fmt.Sprintf(strings.Join(prices, " AND "), effectivePriceSQL("now()"))))
+	}
+
+	if filter.Category != "" {
+		q.and("category_id IN (" + categoryDescendantsQuery(q.bind(filter.Category)) + ")")
 	}
 
 	if filter.Search != "" {
-		whereSQL = append(whereSQL, " name ILIKE '%"+filter.Search+"%'")
+		// full text matches words in name and tags, the substring match keeps
+		// partial words working
+		q.and(fmt.Sprintf("(search_vector @@ %s OR name ILIKE %s)",
+			productTSQuery(q, filter.Search),
+			q.bind("%"+escapeLike(filter.Search)+"%")))
+	}
+
+	return q
+}
+
+// constructOrderQuery appends the keyset condition of filter.Cursor to q and
+// returns the ORDER BY clause. Rows are always ordered by id last so that pages
+// are stable.
+func (p *Product) constructOrderQuery(q *queryBuilder, filter entity.FilterGetProducts) (string, error) {
+	column, direction := "", "ASC"
+
+	sort, ok := productSortColumns[filter.SortBy]
+	if ok {
+		if filter.SortBy == "relevance" && filter.Search == "" {
+			return "", ErrInvalidSort
+		}
+
+		column = sort.column(q, filter)
+		if sort.descending {
+			direction = "DESC"
+		}
+	} else if filter.SortBy != "" {
+		return "", ErrInvalidSort
+	}
+
+	if filter.OrderBy != "" {
+		direction, ok = productOrderDirections[filter.OrderBy]
+		if !ok {
+			return "", ErrInvalidSort
+		}
 	}
 
-	if len(whereSQL) > 0 {
-		return " WHERE " + strings.Join(whereSQL, " AND ")
+	if cursor := filter.Cursor; cursor != nil {
+		if cursor.SortBy != filter.SortBy || cursor.OrderBy != filter.OrderBy {
+			return "", ErrInvalidCursor
+		}
+
+		if cursor.Backward {
+			direction = map[string]string{"ASC": "DESC", "DESC": "ASC"}[direction]
+		}
+
+		op := ">"
+		if direction == "DESC" {
+			op = "<"
+		}
+
+		if column == "" {
+			q.and("id " + op + " " + q.bind(cursor.ID))
+		} else {
+			q.and(fmt.Sprintf("(%s, id) %s (%s::%s, %s)", column, op, q.bind(cursor.Key), sort.cast, q.bind(cursor.ID)))
+		}
+	}
+
+	if column == "" {
+		return " ORDER BY id " + direction, nil
 	}
 
-	return ""
+	return " ORDER BY " + column + " " + direction + ", id " + direction, nil
 }
 
+// NewProductCursor returns the cursor pointing at product within the listing
+// described by filter. A backward cursor pages towards the start of the listing.
+func NewProductCursor(filter entity.FilterGetProducts, product entity.Product, backward bool) entity.ProductCursor {
+	cursor := entity.ProductCursor{
+		SortBy:   filter.SortBy,
+		OrderBy:  filter.OrderBy,
+		ID:       product.ID,
+		Backward: backward,
+	}
+
+	if sort, ok := productSortColumns[filter.SortBy]; ok {
+		cursor.Key = sort.key(product)
+	}
+
+	return cursor
+}
+
+// FindAll returns the products matching filter. When filter.Cursor is set the
+// page is resolved relative to the cursor instead of filter.Offset, products are
+// returned in listing order either way.
 func (p *Product) FindAll(ctx context.Context, filter entity.FilterGetProducts, userID int) ([]entity.Product, error) {
 	conn, err := p.dbPool.Acquire(ctx)
 	if err != nil {
@@ -67,31 +265,33 @@ func (p *Product) FindAll(ctx context.Context, filter entity.FilterGetProducts,
 
 	defer conn.Release()
 
-	sql := `SELECT id, user_id, name, price, image_url, stock, condition, tags, is_purchaseable, purchase_count FROM products`
+	q := p.constructWhereQuery(filter, userID)
 
-	sql += p.constructWhereQuery(ctx, filter, userID)
+	orderSQL, err := p.constructOrderQuery(q, filter)
+	if err != nil {
+		return nil, err
+	}
 
-	if filter.SortBy != "" {
-		if filter.SortBy == "date" {
-			filter.SortBy = "created_at"
-		}
-		if filter.OrderBy == "" {
-			filter.OrderBy = "ASC"
-		} else if filter.OrderBy == "dsc" {
-			filter.OrderBy = "DESC"
-		}
-		sql += " ORDER BY " + filter.SortBy + " " + filter.OrderBy
+	sql := `SELECT ` + productColumns
+	if filter.Search != "" {
+		tsQuery := productTSQuery(q, filter.Search)
+		sql += `, ts_rank(search_vector, ` + tsQuery + `)`
+		sql += `, ts_headline('simple', name, ` + tsQuery + `, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>')`
 	}
 
+	sql += ` FROM products`
+	sql += q.where()
+	sql += orderSQL
+
 	if filter.Limit > 0 {
-		sql += " LIMIT " + fmt.Sprintf("%d", filter.Limit)
+		sql += " LIMIT " + q.bind(filter.Limit)
 	}
 
-	if filter.Offset > 0 {
-		sql += " OFFSET " + fmt.Sprintf("%d", filter.Offset)
+	if filter.Offset > 0 && filter.Cursor == nil {
+		sql += " OFFSET " + q.bind(filter.Offset)
 	}
 
-	rows, err := conn.Query(ctx, sql)
+	rows, err := conn.Query(ctx, sql, q.args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed get products: %v", err)
 	}
@@ -102,13 +302,22 @@ func (p *Product) FindAll(ctx context.Context, filter entity.FilterGetProducts,
 
 	for rows.Next() {
 		product := entity.Product{}
-		err := rows.Scan(&product.ID, &product.UserID, &product.Name, &product.Price, &product.ImageUrl, &product.Stock, &product.Condition, &product.Tags, &product.IsPurchaseable, &product.PurchaseCount)
+		fields := productFields(&product)
+		if filter.Search != "" {
+			fields = append(fields, &product.Relevance, &product.Highlight)
+		}
+
+		err := rows.Scan(fields...)
 		if err != nil {
 			return nil, fmt.Errorf("failed scan products: %v", err)
 		}
 		products = append(products, product)
 	}
 
+	if filter.Cursor != nil && filter.Cursor.Backward {
+		slices.Reverse(products)
+	}
+
 	return products, nil
 }
 
@@ -120,12 +329,12 @@ func (p *Product) Count(ctx context.Context, filter entity.FilterGetProducts, us
 
 	defer conn.Release()
 
-	sql := `SELECT COUNT(id) FROM products`
+	q := p.constructWhereQuery(filter, userID)
 
-	sql += p.constructWhereQuery(ctx, filter, userID)
+	sql := `SELECT COUNT(id) FROM products` + q.where()
 
 	var count int
-	err = conn.QueryRow(ctx, sql).Scan(&count)
+	err = conn.QueryRow(ctx, sql, q.args...).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed get products count: %v", err)
 	}
@@ -133,6 +342,29 @@ func (p *Product) Count(ctx context.Context, filter entity.FilterGetProducts, us
 	return count, nil
 }
 
+// checkProductVersion locks the product and returns ErrVersionMismatch when
+// version is set and is not the current version of the product.
+func checkProductVersion(ctx context.Context, tx pgx.Tx, productID, version int) error {
+	if version == 0 {
+		return nil
+	}
+
+	var current int
+	err := tx.QueryRow(ctx, `select version from products where id = $1 and deleted_at is null for update`, productID).Scan(&current)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRow
+		}
+		return fmt.Errorf("failed get product version: %v", err)
+	}
+
+	if current != version {
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
 func (p *Product) SumPurchaseCountByUserID(ctx context.Context, userID int) (int, error) {
 	conn, err := p.dbPool.Acquire(ctx)
 	if err != nil {
@@ -195,8 +427,10 @@ func (p *Product) Buy(ctx context.Context, payment entity.Payment) (entity.Payme
 
 	product := entity.ProductPayment{}
 
-	err = tx.QueryRow(ctx, "select id, name, image_url, stock, price from products where id = $1 for update", payment.ProductId).Scan(
-		&product.Id, &product.Name, &product.ImageUrl, &product.Qty, &product.Price,
+	// Qty is the stock that is not held by reservations, the price is the one
+	// in force now rather than when the transaction started
+	err = tx.QueryRow(ctx, "select id, user_id, name, image_url, stock - "+heldStockSQL("product_id", "products.id")+", "+effectivePriceSQL("clock_timestamp()")+", currency from products where id = $1 and deleted_at is null and status = 'published' for update", payment.ProductId).Scan(
+		&product.Id, &product.SellerId, &product.Name, &product.ImageUrl, &product.Qty, &product.Price.Amount, &product.Price.Currency,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		tx.Rollback(ctx)
@@ -208,27 +442,190 @@ func (p *Product) Buy(ctx context.Context, payment entity.Payment) (entity.Payme
 		return entity.Payment{}, fmt.Errorf("failed get product when do payment: %v", err)
 	}
 
-	if product.Qty < payment.Qty {
+	before, err := snapshotProduct(ctx, tx, payment.ProductId)
+	if err != nil {
+		tx.Rollback(ctx)
+		return entity.Payment{}, err
+	}
+
+	// a reservation decides the variant and quantity, the units it holds are
+	// available to its buyer
+	var reservedQty int
+	if payment.ReservationId != 0 {
+		var reservedVariantID *int
+		err = tx.QueryRow(ctx, "select variant_id, qty from stock_reservations where id = $1 and product_id = $2 and user_id = $3 and status = 'active' and expires_at > now() for update", payment.ReservationId, payment.ProductId, payment.BuyerId).Scan(
+			&reservedVariantID, &reservedQty,
+		)
+		if errors.Is(err, pgx.ErrNoRows) {
+			tx.Rollback(ctx)
+			return entity.Payment{}, ErrReservationNotFound
+		}
+
+		if err != nil {
+			tx.Rollback(ctx)
+			return entity.Payment{}, fmt.Errorf("failed get stock reservation when do payment: %v", err)
+		}
+
+		if payment.Qty == 0 {
+			payment.Qty = reservedQty
+		}
+
+		if payment.VariantId == 0 && reservedVariantID != nil {
+			payment.VariantId = *reservedVariantID
+		}
+
+		if payment.Qty != reservedQty || (reservedVariantID == nil && payment.VariantId != 0) || (reservedVariantID != nil && *reservedVariantID != payment.VariantId) {
+			tx.Rollback(ctx)
+			return entity.Payment{}, ErrReservationMismatch
+		}
+	}
+
+	var hasVariants bool
+	err = tx.QueryRow(ctx, "select exists (select 1 from product_variants where product_id = $1)", payment.ProductId).Scan(&hasVariants)
+	if err != nil {
+		tx.Rollback(ctx)
+		return entity.Payment{}, fmt.Errorf("failed check product variants: %v", err)
+	}
+
+	if hasVariants && payment.VariantId == 0 {
+		tx.Rollback(ctx)
+		return entity.Payment{}, ErrVariantRequired
+	}
+
+	if payment.VariantId != 0 {
+		var (
+			sku      string
+			imageUrl string
+		)
+
+		err = tx.QueryRow(ctx, "select sku, image_url, stock - "+heldStockSQL("variant_id", "product_variants.id")+", price from product_variants where id = $1 and product_id = $2 for update", payment.VariantId, payment.ProductId).Scan(
+			&sku, &imageUrl, &product.Qty, &product.Price.Amount,
+		)
+		if errors.Is(err, pgx.ErrNoRows) {
+			tx.Rollback(ctx)
+			return entity.Payment{}, ErrNoRow
+		}
+
+		if err != nil {
+			tx.Rollback(ctx)
+			return entity.Payment{}, fmt.Errorf("failed get product variant when do payment: %v", err)
+		}
+
+		product.VariantSKU = &sku
+		if imageUrl != "" {
+			product.ImageUrl = imageUrl
+		}
+	}
+
+	if product.Qty+reservedQty < payment.Qty {
 		tx.Rollback(ctx)
 		return entity.Payment{}, ErrInsuficientQty
 	}
 
+	if payment.VariantId != 0 {
+		_, err = tx.Exec(ctx, "update product_variants set stock = stock - $1, purchase_count = purchase_count + $1, updated_at = now() where id = $2", payment.Qty, payment.VariantId)
+		if err != nil {
+			tx.Rollback(ctx)
+			return entity.Payment{}, fmt.Errorf("failed update product variant stock: %v", err)
+		}
+	}
+
 	_, err = tx.Exec(ctx, "update products set stock = stock - $1, purchase_count = purchase_count + $1 where id = $2", payment.Qty, payment.ProductId)
 	if err != nil {
 		tx.Rollback(ctx)
 		return entity.Payment{}, fmt.Errorf("failed update product stock: %v", err)
 	}
 
-	err = tx.QueryRow(ctx, `INSERT INTO payments (product_id, product_name, product_image_url, product_qty, product_price, user_id, buyer_username, buyer_name, bank_name, bank_account_name, bank_account_number, payment_proof_image_url) VALUES (
-		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+	if payment.VariantId != 0 {
+		if err := syncProductVariants(ctx, tx, payment.ProductId); err != nil {
+			tx.Rollback(ctx)
+			return entity.Payment{}, err
+		}
+	}
+
+	var variantID *int
+	if payment.VariantId != 0 {
+		variantID = &payment.VariantId
+	}
+
+	// the amounts are kept on the payment as the voucher and the price may
+	// change later
+	payment.Subtotal, err = product.Price.Mul(int64(payment.Qty))
+	if err != nil {
+		tx.Rollback(ctx)
+		return entity.Payment{}, err
+	}
+
+	payment.Discount = money.New(0, product.Price.Currency)
+
+	var (
+		voucherID   *int
+		voucherCode *string
+	)
+	if payment.VoucherCode != "" {
+		voucher, discount, err := applyVoucher(ctx, tx, payment.VoucherCode, payment.BuyerId, product.SellerId, payment.Subtotal, time.Now())
+		if err != nil {
+			tx.Rollback(ctx)
+			return entity.Payment{}, err
+		}
+
+		voucherID, voucherCode = &voucher.ID, &voucher.Code
+		payment.VoucherCode = voucher.Code
+		payment.Discount = discount
+	}
+
+	payment.TotalAmount, err = payment.Subtotal.Sub(payment.Discount)
+	if err != nil {
+		tx.Rollback(ctx)
+		return entity.Payment{}, err
+	}
+
+	err = tx.QueryRow(ctx, `INSERT INTO payments (product_id, product_name, product_image_url, product_qty, product_price, user_id, buyer_username, buyer_name, bank_name, bank_account_name, bank_account_number, payment_proof_image_url, variant_id, variant_sku, buyer_id, voucher_id, voucher_code, subtotal, discount, total_amount, currency) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21
 	) RETURNING id, created_at, updated_at`,
-		product.Id, product.Name, product.ImageUrl, payment.Qty, product.Price, user.UserId, user.BuyerUsername, user.BuyerName, bankAccount.BankName, bankAccount.BankAccountName, bankAccount.BankAccountNumber, payment.PaymentProofImageUrl,
+		product.Id, product.Name, product.ImageUrl, payment.Qty, product.Price.Amount, user.UserId, user.BuyerUsername, user.BuyerName, bankAccount.BankName, bankAccount.BankAccountName, bankAccount.BankAccountNumber, payment.PaymentProofImageUrl, variantID, product.VariantSKU, payment.BuyerId, voucherID, voucherCode, payment.Subtotal.Amount, payment.Discount.Amount, payment.TotalAmount.Amount, product.Price.Currency,
 	).Scan(&payment.Id, &payment.CreatedAt, &payment.UpdatedAt)
 	if err != nil {
 		tx.Rollback(ctx)
 		return entity.Payment{}, fmt.Errorf("failed create payment: %v", err)
 	}
 
+	paymentID, _ := strconv.Atoi(payment.Id)
+
+	if voucherID != nil {
+		err = redeemVoucher(ctx, tx, *voucherID, payment.BuyerId, paymentID, payment.Discount.Amount)
+		if err != nil {
+			tx.Rollback(ctx)
+			return entity.Payment{}, err
+		}
+	}
+
+	if payment.ReservationId != 0 {
+		_, err = tx.Exec(ctx, "update stock_reservations set status = 'consumed', payment_id = $1, updated_at = now() where id = $2", paymentID, payment.ReservationId)
+		if err != nil {
+			tx.Rollback(ctx)
+			return entity.Payment{}, fmt.Errorf("failed consume stock reservation: %v", err)
+		}
+	}
+
+	_, err = recordStockMovement(ctx, tx, payment.BuyerId, entity.StockMovement{
+		ProductID: payment.ProductId,
+		VariantID: variantID,
+		Type:      entity.StockMovementSale,
+		Delta:     -payment.Qty,
+		PaymentID: &paymentID,
+	})
+	if err != nil {
+		tx.Rollback(ctx)
+		return entity.Payment{}, err
+	}
+
+	err = recordProductRevision(ctx, tx, payment.ProductId, payment.BuyerId, entity.ProductRevisionPurchase, before)
+	if err != nil {
+		tx.Rollback(ctx)
+		return entity.Payment{}, err
+	}
+
 	tx.Commit(ctx)
 
 	return payment, nil
@@ -242,72 +639,252 @@ func (p *Product) Add(ctx context.Context, product entity.Product) (entity.Produ
 
 	defer conn.Release()
 
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.Product{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	product, err = insertProduct(ctx, tx, product)
+	if err != nil {
+		return entity.Product{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.Product{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return product, nil
+}
+
+func insertProduct(ctx context.Context, tx pgx.Tx, product entity.Product) (entity.Product, error) {
 	sql := `
-		insert into products (user_id, name, price, image_url, stock, condition, tags, is_purchaseable, purchase_count) 
-		values ($1, $2, $3, $4, $5, $6, $7, $8, 0)
+		insert into products (user_id, name, price, image_url, stock, condition, tags, is_purchaseable, purchase_count, category_id, status, publish_at, low_stock_threshold, sale_price, sale_starts_at, sale_ends_at, currency) 
+		values ($1, $2, $3, $4, $5, $6, $7, $8, 0, $9, coalesce(nullif($10, ''), 'published'), $11, $12, $13, $14, $15, coalesce(nullif($16, ''), '` + money.DefaultCurrency + `'))
+		returning id, created_at, updated_at, status, currency, ` + effectivePriceSQL("now()") + `, currency
 	`
 
-	_, err = conn.Exec(ctx, sql,
+	err := tx.QueryRow(ctx, sql,
 		product.UserID,
 		product.Name,
-		product.Price,
+		product.Price.Amount,
 		product.ImageUrl,
 		product.Stock,
 		product.Condition,
 		product.Tags,
-		product.IsPurchaseable)
+		product.IsPurchaseable,
+		product.CategoryID,
+		product.Status,
+		product.PublishAt,
+		product.LowStockThreshold,
+		product.SalePrice,
+		product.SaleStartsAt,
+		product.SaleEndsAt,
+		product.Price.Currency).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt, &product.Status, &product.Price.Currency, &product.EffectivePrice.Amount, &product.EffectivePrice.Currency)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
 			return entity.Product{}, ErrProductNameDuplicate
+		} else if strings.Contains(err.Error(), "violates foreign key constraint") {
+			return entity.Product{}, ErrCategoryNotFound
 		}
 		return entity.Product{}, fmt.Errorf("failed insert product: %v", err)
 	}
 
-	var result entity.Product
-
-	err = conn.QueryRow(ctx, `SELECT id FROM products WHERE name = $1`, product.Name).Scan(&result.ID)
+	// the product image becomes the cover of the gallery
+	_, err = tx.Exec(ctx, `insert into product_images (product_id, image_url, position, is_cover) values ($1, $2, 0, true)`, product.ID, product.ImageUrl)
+	if err != nil {
+		return entity.Product{}, fmt.Errorf("failed insert product image: %v", err)
+	}
 
+	_, err = recordStockMovement(ctx, tx, product.UserID, entity.StockMovement{
+		ProductID: product.ID,
+		Type:      entity.StockMovementRestock,
+		Delta:     product.Stock,
+		Reason:    "initial stock",
+	})
 	if err != nil {
 		return entity.Product{}, err
 	}
-	product.ID = result.ID
+
+	if err := recordProductRevision(ctx, tx, product.ID, product.UserID, entity.ProductRevisionCreate, nil); err != nil {
+		return entity.Product{}, err
+	}
 
 	return product, nil
 }
 
-func (p *Product) Update(ctx context.Context, product entity.Product) error {
+func (p *Product) Update(ctx context.Context, product entity.Product) (entity.Product, error) {
 	conn, err := p.dbPool.Acquire(ctx)
 	if err != nil {
-		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+		return entity.Product{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
 	}
 
 	defer conn.Release()
 
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.Product{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	product, err = updateProduct(ctx, tx, product)
+	if err != nil {
+		return entity.Product{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.Product{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return product, nil
+}
+
+func updateProduct(ctx context.Context, tx pgx.Tx, product entity.Product) (entity.Product, error) {
+	if err := checkProductVersion(ctx, tx, product.ID, product.Version); err != nil {
+		return entity.Product{}, err
+	}
+
+	before, err := snapshotProduct(ctx, tx, product.ID)
+	if err != nil {
+		return entity.Product{}, err
+	}
+
+	var previousStock int
+	err = tx.QueryRow(ctx, `select stock from products where id = $1 for update`, product.ID).Scan(&previousStock)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return entity.Product{}, fmt.Errorf("failed get product stock: %v", err)
+	}
+
 	sql := `
-		update products set name = $1, price = $2, image_url = $3, stock = $4, condition = $5, tags = $6, is_purchaseable = $7, updated_at = now()
-		where id = $8 and user_id = $9
+		update products set name = $1, image_url = $3, condition = $5, tags = $6, is_purchaseable = $7, category_id = $10, updated_at = now(),
+			status = coalesce(nullif($11, ''), status),
+			publish_at = case when $11 = '' then publish_at else $12 end,
+			low_stock_threshold = $13,
+			price = case when exists (select 1 from product_variants where product_id = $8) then price else $2 end,
+			stock = case when exists (select 1 from product_variants where product_id = $8) then stock else $4 end,
+			sale_price = case when exists (select 1 from product_variants where product_id = $8) then null else $14 end,
+			sale_starts_at = $15,
+			sale_ends_at = $16,
+			currency = coalesce(nullif($17, ''), currency)
+		where id = $8 and user_id = $9 and deleted_at is null
+		returning updated_at, version, stock, price, currency, sale_price, ` + effectivePriceSQL("now()") + `, currency
 	`
 
-	_, err = conn.Exec(ctx, sql,
+	err = tx.QueryRow(ctx, sql,
 		product.Name,
-		product.Price,
+		product.Price.Amount,
 		product.ImageUrl,
 		product.Stock,
 		product.Condition,
 		product.Tags,
 		product.IsPurchaseable,
 		product.ID,
-		product.UserID)
+		product.UserID,
+		product.CategoryID,
+		product.Status,
+		product.PublishAt,
+		product.LowStockThreshold,
+		product.SalePrice,
+		product.SaleStartsAt,
+		product.SaleEndsAt,
+		product.Price.Currency).Scan(&product.UpdatedAt, &product.Version, &product.Stock, &product.Price.Amount, &product.Price.Currency, &product.SalePrice, &product.EffectivePrice.Amount, &product.EffectivePrice.Currency)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return ErrNoRow
+			return entity.Product{}, ErrNoRow
 		} else if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			return ErrProductNameDuplicate
+			return entity.Product{}, ErrProductNameDuplicate
+		} else if strings.Contains(err.Error(), "violates foreign key constraint") {
+			return entity.Product{}, ErrCategoryNotFound
 		}
-		return fmt.Errorf("failed update product: %v", err)
+		return entity.Product{}, fmt.Errorf("failed update product: %v", err)
 	}
 
-	return nil
+	// image_url always mirrors the cover of the gallery
+	_, err = tx.Exec(ctx, `update product_images set image_url = $1 where product_id = $2 and is_cover`, product.ImageUrl, product.ID)
+	if err != nil {
+		return entity.Product{}, fmt.Errorf("failed update product cover image: %v", err)
+	}
+
+	_, err = recordStockMovement(ctx, tx, product.UserID, entity.StockMovement{
+		ProductID: product.ID,
+		Type:      entity.StockMovementAdjustment,
+		Delta:     product.Stock - previousStock,
+		Reason:    "product updated",
+	})
+	if err != nil {
+		return entity.Product{}, err
+	}
+
+	if err := recordProductRevision(ctx, tx, product.ID, product.UserID, entity.ProductRevisionUpdate, before); err != nil {
+		return entity.Product{}, err
+	}
+
+	return product, nil
+}
+
+// Import creates or updates, matched by name, the products of userID. Every
+// product is applied in its own savepoint so one failing row does not affect
+// the others. With dryRun nothing is kept.
+func (p *Product) Import(ctx context.Context, userID int, products []entity.Product, dryRun bool) ([]entity.ProductImportResult, error) {
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	results := []entity.ProductImportResult{}
+
+	for _, product := range products {
+		product.UserID = userID
+		result := entity.ProductImportResult{}
+
+		err := pgx.BeginFunc(ctx, tx, func(tx pgx.Tx) error {
+			err := tx.QueryRow(ctx, `select id from products where name = $1 and user_id = $2 and deleted_at is null for update`, product.Name, userID).Scan(&product.ID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				product, err = insertProduct(ctx, tx, product)
+				result.Action = entity.ProductImportCreated
+			} else if err == nil {
+				product, err = updateProduct(ctx, tx, product)
+				result.Action = entity.ProductImportUpdated
+			}
+
+			return err
+		})
+		if err != nil {
+			result = entity.ProductImportResult{Action: entity.ProductImportFailed, Error: err.Error()}
+		} else {
+			result.ProductID = product.ID
+		}
+
+		results = append(results, result)
+	}
+
+	if dryRun {
+		// ids of products created in a dry run do not exist
+		for i := range results {
+			if results[i].Action == entity.ProductImportCreated {
+				results[i].ProductID = 0
+			}
+		}
+
+		return results, nil
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return results, nil
 }
 
 func (p *Product) FindByID(ctx context.Context, productID int) (entity.Product, error) {
@@ -320,9 +897,7 @@ func (p *Product) FindByID(ctx context.Context, productID int) (entity.Product,
 
 	var product entity.Product
 
-	err = conn.QueryRow(ctx, `SELECT id, user_id, name, price, image_url, stock, condition, tags, is_purchaseable, purchase_count FROM products WHERE id = $1`, productID).Scan(
-		&product.ID, &product.UserID, &product.Name, &product.Price, &product.ImageUrl, &product.Stock, &product.Condition, &product.Tags, &product.IsPurchaseable, &product.PurchaseCount,
-	)
+	err = scanProduct(conn.QueryRow(ctx, `SELECT `+productColumns+` FROM products WHERE id = $1 AND deleted_at IS NULL`, productID), &product)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -344,9 +919,7 @@ func (p *Product) FindByIDUser(ctx context.Context, productID int, userID int) (
 
 	var product entity.Product
 
-	err = conn.QueryRow(ctx, `SELECT id, user_id, name, price, image_url, stock, condition, tags, is_purchaseable, purchase_count FROM products WHERE id = $1 AND user_id = $2`, productID, userID).Scan(
-		&product.ID, &product.UserID, &product.Name, &product.Price, &product.ImageUrl, &product.Stock, &product.Condition, &product.Tags, &product.IsPurchaseable, &product.PurchaseCount,
-	)
+	err = scanProduct(conn.QueryRow(ctx, `SELECT `+productColumns+` FROM products WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`, productID, userID), &product)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return entity.Product{}, ErrNoRow
@@ -364,9 +937,20 @@ func (p *Product) UpdateStock(ctx context.Context, product entity.Product, userI
 	}
 	defer conn.Release()
 
-	sqlCheck := `SELECT id FROM products WHERE id = $1 AND user_id = $2`
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.Product{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	sqlCheck := `SELECT id, stock, exists (select 1 from product_variants where product_id = products.id) FROM products WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE`
 
-	err = conn.QueryRow(ctx, sqlCheck, product.ID, userID).Scan(&product.ID)
+	var (
+		previousStock int
+		hasVariants   bool
+	)
+	err = tx.QueryRow(ctx, sqlCheck, product.ID, userID).Scan(&product.ID, &previousStock, &hasVariants)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return entity.Product{}, ErrNoRow
@@ -374,18 +958,51 @@ func (p *Product) UpdateStock(ctx context.Context, product entity.Product, userI
 		return entity.Product{}, fmt.Errorf("failed get product: %v", err)
 	}
 
+	if hasVariants {
+		return entity.Product{}, ErrVariantRequired
+	}
+
+	if err := checkProductVersion(ctx, tx, product.ID, product.Version); err != nil {
+		return entity.Product{}, err
+	}
+
+	before, err := snapshotProduct(ctx, tx, product.ID)
+	if err != nil {
+		return entity.Product{}, err
+	}
+
 	sql := `
-		update products set stock = $1, updated_at = now() where id = $2 AND user_id = $3
+		update products set stock = $1, updated_at = now() where id = $2 AND user_id = $3 returning version
 	`
 
-	_, err = conn.Exec(ctx, sql, product.Stock, product.ID, userID)
+	err = tx.QueryRow(ctx, sql, product.Stock, product.ID, userID).Scan(&product.Version)
 	if err != nil {
 		return entity.Product{}, fmt.Errorf("failed update product stock: %v", err)
 	}
+
+	_, err = recordStockMovement(ctx, tx, userID, entity.StockMovement{
+		ProductID: product.ID,
+		Type:      entity.StockMovementAdjustment,
+		Delta:     product.Stock - previousStock,
+		Reason:    "stock set",
+	})
+	if err != nil {
+		return entity.Product{}, err
+	}
+
+	if err := recordProductRevision(ctx, tx, product.ID, userID, entity.ProductRevisionStock, before); err != nil {
+		return entity.Product{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.Product{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
 	return product, nil
 }
 
-func (p *Product) DeleteByID(ctx context.Context, productID int) error {
+// DeleteByID soft deletes the product, it can be restored until it is purged.
+func (p *Product) DeleteByID(ctx context.Context, productID int, userID int, version int) error {
 	conn, err := p.dbPool.Acquire(ctx)
 	if err != nil {
 		return fmt.Errorf("failed acquire db connection from pool: %v", err)
@@ -393,11 +1010,219 @@ func (p *Product) DeleteByID(ctx context.Context, productID int) error {
 
 	defer conn.Release()
 
-	sql := `delete from products where id = $1`
-	_, err = conn.Exec(ctx, sql, productID)
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	if err := checkProductVersion(ctx, tx, productID, version); err != nil {
+		return err
+	}
+
+	before, err := snapshotProduct(ctx, tx, productID)
+	if err != nil {
+		return err
+	}
+
+	sql := `update products set deleted_at = now() where id = $1 and deleted_at is null`
+	tag, err := tx.Exec(ctx, sql, productID)
 	if err != nil {
 		return fmt.Errorf("failed delete product: %v", err)
 	}
 
+	if tag.RowsAffected() == 0 {
+		return ErrNoRow
+	}
+
+	if err := recordProductRevision(ctx, tx, productID, userID, entity.ProductRevisionDelete, before); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit transaction: %v", err)
+	}
+
 	return nil
 }
+
+// EachByUserID calls fn for every product of the user that is not deleted,
+// in id order, without loading them all in memory.
+func (p *Product) EachByUserID(ctx context.Context, userID int, fn func(product entity.Product) error) error {
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT `+productColumns+` FROM products WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id`, userID)
+	if err != nil {
+		return fmt.Errorf("failed get products: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		product := entity.Product{}
+		if err := scanProduct(rows, &product); err != nil {
+			return fmt.Errorf("failed scan products: %v", err)
+		}
+
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// FindDeletedByUserID returns the products of the user deleted after since,
+// most recently deleted first.
+func (p *Product) FindDeletedByUserID(ctx context.Context, userID int, since time.Time) ([]entity.Product, error) {
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT `+productColumns+` FROM products WHERE user_id = $1 AND deleted_at >= $2 ORDER BY deleted_at DESC, id DESC`, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed get deleted products: %v", err)
+	}
+
+	defer rows.Close()
+
+	products := []entity.Product{}
+
+	for rows.Next() {
+		product := entity.Product{}
+		err := scanProduct(rows, &product)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan products: %v", err)
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+// Restore undoes the deletion of a product deleted after since.
+func (p *Product) Restore(ctx context.Context, productID, userID int, since time.Time) (entity.Product, error) {
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.Product{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.Product{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	before, err := snapshotProduct(ctx, tx, productID)
+	if err != nil {
+		return entity.Product{}, err
+	}
+
+	var product entity.Product
+
+	err = scanProduct(tx.QueryRow(ctx, `
+		update products set deleted_at = null, updated_at = now()
+		where id = $1 and user_id = $2 and deleted_at >= $3
+		returning `+productColumns,
+		productID, userID, since), &product)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Product{}, ErrNoRow
+		} else if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return entity.Product{}, ErrProductNameDuplicate
+		}
+		return entity.Product{}, fmt.Errorf("failed restore product: %v", err)
+	}
+
+	if err := recordProductRevision(ctx, tx, productID, userID, entity.ProductRevisionRestore, before); err != nil {
+		return entity.Product{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.Product{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return product, nil
+}
+
+// PurgeDeleted permanently removes products deleted before the given time.
+func (p *Product) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `delete from products where deleted_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed purge deleted products: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// PublishScheduled publishes the scheduled products whose publish_at is past.
+func (p *Product) PublishScheduled(ctx context.Context) (int64, error) {
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		select id from products
+		where status = 'scheduled' and publish_at <= now() and deleted_at is null
+		for update skip locked
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed get scheduled products: %v", err)
+	}
+
+	productIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return 0, fmt.Errorf("failed scan scheduled products: %v", err)
+	}
+
+	for _, productID := range productIDs {
+		before, err := snapshotProduct(ctx, tx, productID)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec(ctx, `update products set status = 'published', updated_at = now() where id = $1`, productID)
+		if err != nil {
+			return 0, fmt.Errorf("failed publish product: %v", err)
+		}
+
+		if err := recordProductRevision(ctx, tx, productID, 0, entity.ProductRevisionPublish, before); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return int64(len(productIDs)), nil
+}
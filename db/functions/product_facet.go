@@ -0,0 +1,132 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"shopifyx/db/entity"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// productFacetTagLimit caps the number of tags returned as facets.
+	productFacetTagLimit = 50
+	// productPriceBuckets is the number of buckets of the price histogram.
+	productPriceBuckets = 5
+)
+
+func collectFacetCounts(rows pgx.Rows) ([]entity.FacetCount, error) {
+	defer rows.Close()
+
+	facets := []entity.FacetCount{}
+	for rows.Next() {
+		facet := entity.FacetCount{}
+		if err := rows.Scan(&facet.Value, &facet.Count); err != nil {
+			return nil, err
+		}
+		facets = append(facets, facet)
+	}
+
+	return facets, rows.Err()
+}
+
+// Facets counts the products matching filter per tag, per condition and per
+// price range, using the same conditions as Count.
+func (p *Product) Facets(ctx context.Context, filter entity.FilterGetProducts, userID int) (entity.ProductFacets, error) {
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.ProductFacets{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	facets := entity.ProductFacets{}
+
+	q := p.constructWhereQuery(filter, userID)
+	rows, err := conn.Query(ctx, `
+		SELECT tag, COUNT(id) FROM products, unnest(tags) AS tag`+q.where()+`
+		GROUP BY tag ORDER BY COUNT(id) DESC, tag LIMIT `+q.bind(productFacetTagLimit), q.args...)
+	if err != nil {
+		return entity.ProductFacets{}, fmt.Errorf("failed get tag facets: %v", err)
+	}
+
+	facets.Tags, err = collectFacetCounts(rows)
+	if err != nil {
+		return entity.ProductFacets{}, fmt.Errorf("failed scan tag facets: %v", err)
+	}
+
+	q = p.constructWhereQuery(filter, userID)
+	rows, err = conn.Query(ctx, `
+		SELECT condition, COUNT(id) FROM products`+q.where()+`
+		GROUP BY condition ORDER BY condition`, q.args...)
+	if err != nil {
+		return entity.ProductFacets{}, fmt.Errorf("failed get condition facets: %v", err)
+	}
+
+	facets.Conditions, err = collectFacetCounts(rows)
+	if err != nil {
+		return entity.ProductFacets{}, fmt.Errorf("failed scan condition facets: %v", err)
+	}
+
+	// buckets share the same width, spread between the lowest and highest price
+	q = p.constructWhereQuery(filter, userID)
+	rows, err = conn.Query(ctx, `
+		WITH f AS (SELECT `+effectivePriceSQL("now()")+` AS price FROM products`+q.where()+`),
+		b AS (SELECT MIN(price) AS lo, GREATEST(1, CEIL((MAX(price) - MIN(price) + 1)::numeric / `+q.bind(productPriceBuckets)+`))::bigint AS width FROM f)
+		SELECT b.lo, b.width, (f.price - b.lo) / b.width AS bucket, COUNT(*) FROM f, b
+		GROUP BY b.lo, b.width, bucket ORDER BY bucket`, q.args...)
+	if err != nil {
+		return entity.ProductFacets{}, fmt.Errorf("failed get price facets: %v", err)
+	}
+
+	defer rows.Close()
+
+	facets.Prices = []entity.PriceBucket{}
+	for rows.Next() {
+		var (
+			lo, width, bucket int64
+			count             int
+		)
+		if err := rows.Scan(&lo, &width, &bucket, &count); err != nil {
+			return entity.ProductFacets{}, fmt.Errorf("failed scan price facets: %v", err)
+		}
+
+		// fill the empty buckets so the histogram is contiguous
+		for i := len(facets.Prices); int64(i) <= bucket; i++ {
+			facets.Prices = append(facets.Prices, entity.PriceBucket{
+				Min: lo + int64(i)*width,
+				Max: lo + int64(i+1)*width - 1,
+			})
+		}
+		facets.Prices[bucket].Count = count
+	}
+
+	return facets, rows.Err()
+}
+
+// PopularTags counts the published products per tag starting with prefix,
+// the most used first.
+func (p *Product) PopularTags(ctx context.Context, prefix string, limit int) ([]entity.FacetCount, error) {
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `
+		SELECT tag, COUNT(id) FROM products, unnest(tags) AS tag
+		WHERE deleted_at IS NULL AND status = 'published' AND starts_with(tag, $1)
+		GROUP BY tag ORDER BY COUNT(id) DESC, tag LIMIT $2
+	`, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed get popular tags: %v", err)
+	}
+
+	tags, err := collectFacetCounts(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed scan popular tags: %v", err)
+	}
+
+	return tags, nil
+}
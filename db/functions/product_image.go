@@ -0,0 +1,294 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shopifyx/db/entity"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ProductImage struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewProductImageFn(dbPool *pgxpool.Pool) *ProductImage {
+	return &ProductImage{
+		dbPool: dbPool,
+	}
+}
+
+const productImageColumns = `id, product_id, image_url, position, is_cover, created_at`
+
+func scanProductImage(row pgx.Row, image *entity.ProductImage) error {
+	return row.Scan(&image.ID, &image.ProductID, &image.ImageUrl, &image.Position, &image.IsCover, &image.CreatedAt)
+}
+
+// setProductCover marks imageID as the only cover of the product and mirrors
+// its url onto products.image_url.
+func setProductCover(ctx context.Context, tx pgx.Tx, productID, imageID int) error {
+	_, err := tx.Exec(ctx, `update product_images set is_cover = false where product_id = $1 and is_cover and id <> $2`, productID, imageID)
+	if err != nil {
+		return fmt.Errorf("failed unset product cover image: %v", err)
+	}
+
+	var imageUrl string
+	err = tx.QueryRow(ctx, `update product_images set is_cover = true, updated_at = now() where id = $1 and product_id = $2 returning image_url`, imageID, productID).Scan(&imageUrl)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRow
+		}
+		return fmt.Errorf("failed set product cover image: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, `update products set image_url = $1, updated_at = now() where id = $2`, imageUrl, productID)
+	if err != nil {
+		return fmt.Errorf("failed update product image url: %v", err)
+	}
+
+	return nil
+}
+
+func (i *ProductImage) FindByProductID(ctx context.Context, productID int) ([]entity.ProductImage, error) {
+	conn, err := i.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT `+productImageColumns+` FROM product_images WHERE product_id = $1 ORDER BY position, id`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed get product images: %v", err)
+	}
+
+	defer rows.Close()
+
+	images := []entity.ProductImage{}
+
+	for rows.Next() {
+		image := entity.ProductImage{}
+		err := scanProductImage(rows, &image)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan product images: %v", err)
+		}
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+// Add appends an image at the end of the product gallery.
+func (i *ProductImage) Add(ctx context.Context, actorID int, image entity.ProductImage) (entity.ProductImage, error) {
+	conn, err := i.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.ProductImage{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.ProductImage{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	before, err := snapshotProduct(ctx, tx, image.ProductID)
+	if err != nil {
+		return entity.ProductImage{}, err
+	}
+
+	// the returned row carries the column default, keep the requested flag
+	isCover := image.IsCover
+
+	err = scanProductImage(tx.QueryRow(ctx, `
+		insert into product_images (product_id, image_url, position)
+		values ($1, $2, (select coalesce(max(position) + 1, 0) from product_images where product_id = $1))
+		returning `+productImageColumns,
+		image.ProductID, image.ImageUrl), &image)
+	if err != nil {
+		return entity.ProductImage{}, fmt.Errorf("failed insert product image: %v", err)
+	}
+
+	if isCover {
+		if err := setProductCover(ctx, tx, image.ProductID, image.ID); err != nil {
+			return entity.ProductImage{}, err
+		}
+
+		err = scanProductImage(tx.QueryRow(ctx, `SELECT `+productImageColumns+` FROM product_images WHERE id = $1`, image.ID), &image)
+		if err != nil {
+			return entity.ProductImage{}, fmt.Errorf("failed get product image: %v", err)
+		}
+	}
+
+	if err := recordProductRevision(ctx, tx, image.ProductID, actorID, entity.ProductRevisionImage, before); err != nil {
+		return entity.ProductImage{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.ProductImage{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return image, nil
+}
+
+func (i *ProductImage) SetCover(ctx context.Context, actorID, productID, imageID int) error {
+	conn, err := i.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	before, err := snapshotProduct(ctx, tx, productID)
+	if err != nil {
+		return err
+	}
+
+	if err := setProductCover(ctx, tx, productID, imageID); err != nil {
+		return err
+	}
+
+	if err := recordProductRevision(ctx, tx, productID, actorID, entity.ProductRevisionImage, before); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Reorder sets the gallery order, imageIDs must list every image of the product.
+func (i *ProductImage) Reorder(ctx context.Context, actorID, productID int, imageIDs []int) error {
+	conn, err := i.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	before, err := snapshotProduct(ctx, tx, productID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `select id from product_images where product_id = $1 for update`, productID)
+	if err != nil {
+		return fmt.Errorf("failed get product images: %v", err)
+	}
+
+	current, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return fmt.Errorf("failed scan product images: %v", err)
+	}
+
+	requested := slices.Clone(imageIDs)
+	slices.Sort(current)
+	slices.Sort(requested)
+	if !slices.Equal(current, requested) {
+		return ErrInvalidImageOrder
+	}
+
+	_, err = tx.Exec(ctx, `
+		update product_images set position = o.position - 1, updated_at = now()
+		from unnest($1::bigint[]) with ordinality as o(id, position)
+		where product_images.id = o.id and product_images.product_id = $2
+	`, imageIDs, productID)
+	if err != nil {
+		return fmt.Errorf("failed reorder product images: %v", err)
+	}
+
+	if err := recordProductRevision(ctx, tx, productID, actorID, entity.ProductRevisionImage, before); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Delete removes an image from the gallery, when it was the cover the next image
+// in order takes its place.
+func (i *ProductImage) Delete(ctx context.Context, actorID, productID, imageID int) error {
+	conn, err := i.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	before, err := snapshotProduct(ctx, tx, productID)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = tx.QueryRow(ctx, `select count(id) from (select id from product_images where product_id = $1 for update) i`, productID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed count product images: %v", err)
+	}
+
+	var isCover bool
+	err = tx.QueryRow(ctx, `delete from product_images where id = $1 and product_id = $2 returning is_cover`, imageID, productID).Scan(&isCover)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRow
+		}
+		return fmt.Errorf("failed delete product image: %v", err)
+	}
+
+	if count <= 1 {
+		return ErrLastProductImage
+	}
+
+	if isCover {
+		var nextID int
+		err = tx.QueryRow(ctx, `select id from product_images where product_id = $1 order by position, id limit 1`, productID).Scan(&nextID)
+		if err != nil {
+			return fmt.Errorf("failed get next product cover image: %v", err)
+		}
+
+		if err := setProductCover(ctx, tx, productID, nextID); err != nil {
+			return err
+		}
+	}
+
+	if err := recordProductRevision(ctx, tx, productID, actorID, entity.ProductRevisionImage, before); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shopifyx/db/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ProductQuestion struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewProductQuestionFn(dbPool *pgxpool.Pool) *ProductQuestion {
+	return &ProductQuestion{
+		dbPool: dbPool,
+	}
+}
+
+const productQuestionColumns = `id, product_id, user_id, question, answer, answered_at, is_hidden, created_at, updated_at`
+
+func scanProductQuestion(row pgx.Row, question *entity.ProductQuestion) error {
+	return row.Scan(
+		&question.ID, &question.ProductID, &question.UserID, &question.Question, &question.Answer, &question.AnsweredAt, &question.IsHidden, &question.CreatedAt, &question.UpdatedAt,
+	)
+}
+
+// FindByProductID returns the visible questions of a product, most recent
+// first. When answeredOnly is set, unanswered questions are left out.
+func (q *ProductQuestion) FindByProductID(ctx context.Context, productID int, answeredOnly bool, limit, offset int) ([]entity.ProductQuestion, int, error) {
+	conn, err := q.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var total int
+	err = conn.QueryRow(ctx, `
+		SELECT COUNT(id) FROM product_questions
+		WHERE product_id = $1 AND NOT is_hidden AND (NOT $2 OR answer IS NOT NULL)
+	`, productID, answeredOnly).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get product questions count: %v", err)
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT `+productQuestionColumns+` FROM product_questions
+		WHERE product_id = $1 AND NOT is_hidden AND (NOT $2 OR answer IS NOT NULL)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, productID, answeredOnly, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get product questions: %v", err)
+	}
+
+	defer rows.Close()
+
+	questions := []entity.ProductQuestion{}
+
+	for rows.Next() {
+		question := entity.ProductQuestion{}
+		err := scanProductQuestion(rows, &question)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed scan product questions: %v", err)
+		}
+		questions = append(questions, question)
+	}
+
+	return questions, total, nil
+}
+
+// Add posts a question and notifies the seller of the product in the same
+// transaction.
+func (q *ProductQuestion) Add(ctx context.Context, question entity.ProductQuestion) (entity.ProductQuestion, error) {
+	conn, err := q.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.ProductQuestion{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.ProductQuestion{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = scanProductQuestion(tx.QueryRow(ctx, `
+		insert into product_questions (product_id, user_id, question)
+		values ($1, $2, $3)
+		returning `+productQuestionColumns,
+		question.ProductID,
+		question.UserID,
+		question.Question), &question)
+	if err != nil {
+		return entity.ProductQuestion{}, fmt.Errorf("failed insert product question: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		insert into notifications (user_id, type, product_id, message)
+		select user_id, $2, id, 'new question on ' || name
+		from products
+		where id = $1 and user_id <> $3
+	`, question.ProductID, entity.NotificationQuestion, question.UserID)
+	if err != nil {
+		return entity.ProductQuestion{}, fmt.Errorf("failed notify product question: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.ProductQuestion{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return question, nil
+}
+
+// Answer sets the seller answer of a question, replacing a previous one, and
+// notifies the user who asked it.
+func (q *ProductQuestion) Answer(ctx context.Context, productID, questionID int, answer string) (entity.ProductQuestion, error) {
+	conn, err := q.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.ProductQuestion{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.ProductQuestion{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	var question entity.ProductQuestion
+
+	err = scanProductQuestion(tx.QueryRow(ctx, `
+		update product_questions set answer = $1, answered_at = now(), updated_at = now()
+		where id = $2 and product_id = $3
+		returning `+productQuestionColumns,
+		answer, questionID, productID), &question)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ProductQuestion{}, ErrQuestionNotFound
+		}
+		return entity.ProductQuestion{}, fmt.Errorf("failed answer product question: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		insert into notifications (user_id, type, product_id, message)
+		select $2, $3, id, 'your question on ' || name || ' was answered'
+		from products
+		where id = $1
+	`, productID, question.UserID, entity.NotificationAnswer)
+	if err != nil {
+		return entity.ProductQuestion{}, fmt.Errorf("failed notify product answer: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.ProductQuestion{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return question, nil
+}
+
+// Moderate hides or shows a question together with its answer.
+func (q *ProductQuestion) Moderate(ctx context.Context, productID, questionID int, hidden bool) (entity.ProductQuestion, error) {
+	conn, err := q.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.ProductQuestion{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var question entity.ProductQuestion
+
+	err = scanProductQuestion(conn.QueryRow(ctx, `
+		update product_questions set is_hidden = $1, updated_at = now()
+		where id = $2 and product_id = $3
+		returning `+productQuestionColumns,
+		hidden, questionID, productID), &question)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ProductQuestion{}, ErrQuestionNotFound
+		}
+		return entity.ProductQuestion{}, fmt.Errorf("failed moderate product question: %v", err)
+	}
+
+	return question, nil
+}
@@ -0,0 +1,82 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"shopifyx/db/entity"
+)
+
+// relatedProductsSQL scores the published, in stock and purchaseable products
+// against the product $1. Each shared tag weighs 3, the same seller 2, each
+// buyer who bought both products 2 (up to 5 buyers) and a close effective
+// price in the same currency up to 2.
+// Candidates need at least a shared tag, the same seller or a co-purchase, and
+// a seller contributes at most $6 products.
+var relatedProductsSQL = `
+	WITH co_purchases AS (
+		SELECT pay.product_id, COUNT(DISTINCT pay.buyer_id) AS buyers FROM payments pay
+		WHERE pay.product_id <> $1 AND pay.buyer_id IN (SELECT buyer_id FROM payments WHERE product_id = $1 AND buyer_id IS NOT NULL)
+		GROUP BY pay.product_id
+	), candidates AS (
+		SELECT c.id AS product_id, c.user_id AS seller_id,
+			cardinality(ARRAY(SELECT unnest(c.tags) INTERSECT SELECT unnest($2::varchar[]))) AS shared_tags,
+			COALESCE(cp.buyers, 0) AS buyers,
+			` + effectivePriceSQL("now()") + ` AS price, c.currency
+		FROM products c
+		LEFT JOIN co_purchases cp ON cp.product_id = c.id
+		WHERE c.id <> $1 AND c.deleted_at IS NULL AND c.status = 'published' AND c.is_purchaseable
+			AND c.stock > ` + heldStockSQL("product_id", "c.id") + `
+	), scored AS (
+		SELECT product_id, seller_id,
+			shared_tags * 3
+			+ CASE WHEN seller_id = $3 THEN 2 ELSE 0 END
+			+ LEAST(buyers, 5) * 2
+			+ CASE WHEN currency = $7 THEN 2 * (1 - LEAST(ABS(price - $4)::real / GREATEST($4, 1), 1)) ELSE 0 END AS score
+		FROM candidates
+		WHERE shared_tags > 0 OR seller_id = $3 OR buyers > 0
+	), ranked AS (
+		SELECT product_id, score, row_number() OVER (PARTITION BY seller_id ORDER BY score DESC, product_id DESC) AS seller_rank
+		FROM scored
+	)
+	SELECT ` + productColumns + ` FROM products
+	JOIN ranked ON ranked.product_id = products.id
+	WHERE ranked.seller_rank <= $6
+	ORDER BY ranked.score DESC, products.id DESC
+	LIMIT $5
+`
+
+// FindRelated returns up to limit products related to product, with at most
+// perSeller products from a single seller.
+func (p *Product) FindRelated(ctx context.Context, product entity.Product, limit, perSeller int) ([]entity.Product, error) {
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tags := product.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	rows, err := conn.Query(ctx, relatedProductsSQL, product.ID, tags, product.UserID, product.EffectivePrice.Amount, limit, perSeller, product.EffectivePrice.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed get related products: %v", err)
+	}
+
+	defer rows.Close()
+
+	products := []entity.Product{}
+
+	for rows.Next() {
+		related := entity.Product{}
+		err := scanProduct(rows, &related)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan related products: %v", err)
+		}
+		products = append(products, related)
+	}
+
+	return products, nil
+}
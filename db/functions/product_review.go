@@ -0,0 +1,242 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shopifyx/db/entity"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// reviewFlagLimit is the number of flags that hides a review until a
+// moderator looks at it.
+const reviewFlagLimit = 3
+
+type ProductReview struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewProductReviewFn(dbPool *pgxpool.Pool) *ProductReview {
+	return &ProductReview{
+		dbPool: dbPool,
+	}
+}
+
+const productReviewColumns = `id, product_id, user_id, rating, body, images, reply, replied_at, flag_count, is_hidden, created_at, updated_at`
+
+func scanProductReview(row pgx.Row, review *entity.ProductReview) error {
+	return row.Scan(
+		&review.ID, &review.ProductID, &review.UserID, &review.Rating, &review.Body, &review.Images, &review.Reply, &review.RepliedAt, &review.FlagCount, &review.IsHidden, &review.CreatedAt, &review.UpdatedAt,
+	)
+}
+
+// productRatingSQL and productReviewCountSQL aggregate the visible reviews of
+// the product of the outer query.
+const (
+	productRatingSQL      = `(SELECT COALESCE(AVG(pr.rating), 0)::real FROM product_reviews pr WHERE pr.product_id = products.id AND NOT pr.is_hidden)`
+	productReviewCountSQL = `(SELECT COUNT(pr.id) FROM product_reviews pr WHERE pr.product_id = products.id AND NOT pr.is_hidden)`
+)
+
+// FindByProductID returns the visible reviews of a product, most recent first.
+func (r *ProductReview) FindByProductID(ctx context.Context, productID, limit, offset int) ([]entity.ProductReview, int, error) {
+	conn, err := r.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var total int
+	err = conn.QueryRow(ctx, `SELECT COUNT(id) FROM product_reviews WHERE product_id = $1 AND NOT is_hidden`, productID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get product reviews count: %v", err)
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT `+productReviewColumns+` FROM product_reviews
+		WHERE product_id = $1 AND NOT is_hidden
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, productID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get product reviews: %v", err)
+	}
+
+	defer rows.Close()
+
+	reviews := []entity.ProductReview{}
+
+	for rows.Next() {
+		review := entity.ProductReview{}
+		err := scanProductReview(rows, &review)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed scan product reviews: %v", err)
+		}
+		reviews = append(reviews, review)
+	}
+
+	return reviews, total, nil
+}
+
+// Add posts the review of a buyer, only users who paid for the product can
+// review it, once.
+func (r *ProductReview) Add(ctx context.Context, review entity.ProductReview) (entity.ProductReview, error) {
+	conn, err := r.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.ProductReview{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var isBuyer bool
+	err = conn.QueryRow(ctx, `select exists (select 1 from payments where product_id = $1 and buyer_id = $2)`, review.ProductID, review.UserID).Scan(&isBuyer)
+	if err != nil {
+		return entity.ProductReview{}, fmt.Errorf("failed check product buyer: %v", err)
+	}
+
+	if !isBuyer {
+		return entity.ProductReview{}, ErrNotVerifiedBuyer
+	}
+
+	err = scanProductReview(conn.QueryRow(ctx, `
+		insert into product_reviews (product_id, user_id, rating, body, images)
+		values ($1, $2, $3, $4, $5)
+		returning `+productReviewColumns,
+		review.ProductID,
+		review.UserID,
+		review.Rating,
+		review.Body,
+		review.Images), &review)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return entity.ProductReview{}, ErrReviewDuplicate
+		}
+		return entity.ProductReview{}, fmt.Errorf("failed insert product review: %v", err)
+	}
+
+	return review, nil
+}
+
+// Reply sets the seller reply of a review, replacing a previous one.
+func (r *ProductReview) Reply(ctx context.Context, productID, reviewID int, reply string) (entity.ProductReview, error) {
+	conn, err := r.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.ProductReview{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var review entity.ProductReview
+
+	err = scanProductReview(conn.QueryRow(ctx, `
+		update product_reviews set reply = $1, replied_at = now(), updated_at = now()
+		where id = $2 and product_id = $3
+		returning `+productReviewColumns,
+		reply, reviewID, productID), &review)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ProductReview{}, ErrReviewNotFound
+		}
+		return entity.ProductReview{}, fmt.Errorf("failed reply product review: %v", err)
+	}
+
+	return review, nil
+}
+
+// Flag reports a review, a user flags a review once. Reviews reaching
+// reviewFlagLimit flags are hidden.
+func (r *ProductReview) Flag(ctx context.Context, productID, reviewID, userID int, reason string) error {
+	conn, err := r.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	err = tx.QueryRow(ctx, `select true from product_reviews where id = $1 and product_id = $2 for update`, reviewID, productID).Scan(&exists)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrReviewNotFound
+		}
+		return fmt.Errorf("failed get product review: %v", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		insert into product_review_flags (review_id, user_id, reason) values ($1, $2, $3)
+		on conflict (review_id, user_id) do nothing
+	`, reviewID, userID, reason)
+	if err != nil {
+		return fmt.Errorf("failed flag product review: %v", err)
+	}
+
+	if tag.RowsAffected() > 0 {
+		_, err = tx.Exec(ctx, `
+			update product_reviews set flag_count = flag_count + 1, is_hidden = is_hidden or flag_count + 1 >= $1, updated_at = now()
+			where id = $2
+		`, reviewFlagLimit, reviewID)
+		if err != nil {
+			return fmt.Errorf("failed update product review flags: %v", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Moderate hides or shows a review, showing it again clears its flags.
+func (r *ProductReview) Moderate(ctx context.Context, productID, reviewID int, hidden bool) (entity.ProductReview, error) {
+	conn, err := r.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.ProductReview{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.ProductReview{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	var review entity.ProductReview
+
+	err = scanProductReview(tx.QueryRow(ctx, `
+		update product_reviews set is_hidden = $1, flag_count = case when $1 then flag_count else 0 end, updated_at = now()
+		where id = $2 and product_id = $3
+		returning `+productReviewColumns,
+		hidden, reviewID, productID), &review)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ProductReview{}, ErrReviewNotFound
+		}
+		return entity.ProductReview{}, fmt.Errorf("failed moderate product review: %v", err)
+	}
+
+	if !hidden {
+		_, err = tx.Exec(ctx, `delete from product_review_flags where review_id = $1`, reviewID)
+		if err != nil {
+			return entity.ProductReview{}, fmt.Errorf("failed clear product review flags: %v", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.ProductReview{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return review, nil
+}
@@ -0,0 +1,100 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shopifyx/db/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ProductRevision struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewProductRevisionFn(dbPool *pgxpool.Pool) *ProductRevision {
+	return &ProductRevision{
+		dbPool: dbPool,
+	}
+}
+
+// productSnapshotSQL renders the product aliased as p, together with its
+// variants and images, as a single jsonb document.
+const productSnapshotSQL = `(to_jsonb(p) - 'search_vector') || jsonb_build_object(
+	'variants', (select coalesce(jsonb_agg(to_jsonb(v) order by v.id), '[]'::jsonb) from product_variants v where v.product_id = p.id),
+	'images', (select coalesce(jsonb_agg(to_jsonb(i) order by i.position, i.id), '[]'::jsonb) from product_images i where i.product_id = p.id)
+)`
+
+// snapshotProduct returns the current state of the product, to be passed as
+// the before state of recordProductRevision.
+func snapshotProduct(ctx context.Context, tx pgx.Tx, productID int) ([]byte, error) {
+	var snapshot []byte
+
+	err := tx.QueryRow(ctx, `select `+productSnapshotSQL+` from products p where p.id = $1`, productID).Scan(&snapshot)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoRow
+		}
+		return nil, fmt.Errorf("failed snapshot product: %v", err)
+	}
+
+	return snapshot, nil
+}
+
+// recordProductRevision stores the change of the product from before to its
+// current state. An actorID of 0 attributes the change to the product owner.
+func recordProductRevision(ctx context.Context, tx pgx.Tx, productID, actorID int, action string, before []byte) error {
+	_, err := tx.Exec(ctx, `
+		insert into product_revisions (product_id, user_id, action, before, after)
+		select p.id, coalesce(nullif($2, 0), p.user_id), $3, $4, `+productSnapshotSQL+`
+		from products p where p.id = $1
+	`, productID, actorID, action, before)
+	if err != nil {
+		return fmt.Errorf("failed record product revision: %v", err)
+	}
+
+	return nil
+}
+
+// FindByProductID returns the revisions of a product, most recent first.
+func (r *ProductRevision) FindByProductID(ctx context.Context, productID, limit, offset int) ([]entity.ProductRevision, int, error) {
+	conn, err := r.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var total int
+	err = conn.QueryRow(ctx, `SELECT COUNT(id) FROM product_revisions WHERE product_id = $1`, productID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get product revisions count: %v", err)
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT id, product_id, user_id, action, before, after, created_at FROM product_revisions
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, productID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get product revisions: %v", err)
+	}
+
+	defer rows.Close()
+
+	revisions := []entity.ProductRevision{}
+
+	for rows.Next() {
+		revision := entity.ProductRevision{}
+		err := rows.Scan(&revision.ID, &revision.ProductID, &revision.UserID, &revision.Action, &revision.Before, &revision.After, &revision.CreatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed scan product revisions: %v", err)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, total, nil
+}
@@ -0,0 +1,358 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shopifyx/db/entity"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ProductVariant struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewProductVariantFn(dbPool *pgxpool.Pool) *ProductVariant {
+	return &ProductVariant{
+		dbPool: dbPool,
+	}
+}
+
+var productVariantColumns = `id, product_id, sku, options, price, stock, image_url, purchase_count, created_at, updated_at, ` +
+	heldStockSQL("variant_id", "product_variants.id")
+
+func scanProductVariant(row pgx.Row, variant *entity.ProductVariant) error {
+	return row.Scan(
+		&variant.ID, &variant.ProductID, &variant.SKU, &variant.Options, &variant.Price, &variant.Stock, &variant.ImageUrl, &variant.PurchaseCount, &variant.CreatedAt, &variant.UpdatedAt, &variant.HeldStock,
+	)
+}
+
+// syncProductVariants copies the variant totals onto the product row, the
+// product stock is the sum of its variants and its price the cheapest variant,
+// sale prices do not apply to them. Products without variants are left
+// untouched.
+func syncProductVariants(ctx context.Context, tx pgx.Tx, productID int) error {
+	_, err := tx.Exec(ctx, `
+		update products p set stock = v.stock, price = v.price, sale_price = null, updated_at = now()
+		from (select coalesce(sum(stock), 0) as stock, min(price) as price from product_variants where product_id = $1) v
+		where p.id = $1 and v.price is not null
+	`, productID)
+	if err != nil {
+		return fmt.Errorf("failed sync product variants: %v", err)
+	}
+
+	return nil
+}
+
+func (v *ProductVariant) FindByProductID(ctx context.Context, productID int) ([]entity.ProductVariant, error) {
+	conn, err := v.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT `+productVariantColumns+` FROM product_variants WHERE product_id = $1 ORDER BY id`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed get product variants: %v", err)
+	}
+
+	defer rows.Close()
+
+	variants := []entity.ProductVariant{}
+
+	for rows.Next() {
+		variant := entity.ProductVariant{}
+		err := scanProductVariant(rows, &variant)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan product variants: %v", err)
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+func (v *ProductVariant) Add(ctx context.Context, actorID int, variant entity.ProductVariant) (entity.ProductVariant, error) {
+	conn, err := v.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.ProductVariant{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.ProductVariant{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	before, err := snapshotProduct(ctx, tx, variant.ProductID)
+	if err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	err = scanProductVariant(tx.QueryRow(ctx, `
+		insert into product_variants (product_id, sku, options, price, stock, image_url)
+		values ($1, $2, $3, $4, $5, $6)
+		returning `+productVariantColumns,
+		variant.ProductID,
+		variant.SKU,
+		variant.Options,
+		variant.Price,
+		variant.Stock,
+		variant.ImageUrl), &variant)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return entity.ProductVariant{}, ErrVariantSKUDuplicate
+		}
+		return entity.ProductVariant{}, fmt.Errorf("failed insert product variant: %v", err)
+	}
+
+	if err := syncProductVariants(ctx, tx, variant.ProductID); err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	_, err = recordStockMovement(ctx, tx, actorID, entity.StockMovement{
+		ProductID: variant.ProductID,
+		VariantID: &variant.ID,
+		Type:      entity.StockMovementRestock,
+		Delta:     variant.Stock,
+		Reason:    "initial stock",
+	})
+	if err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	if err := recordProductRevision(ctx, tx, variant.ProductID, actorID, entity.ProductRevisionVariant, before); err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.ProductVariant{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return variant, nil
+}
+
+func (v *ProductVariant) Update(ctx context.Context, actorID int, variant entity.ProductVariant) (entity.ProductVariant, error) {
+	conn, err := v.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.ProductVariant{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.ProductVariant{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	before, err := snapshotProduct(ctx, tx, variant.ProductID)
+	if err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	var previousStock int
+	err = tx.QueryRow(ctx, `select stock from product_variants where id = $1 and product_id = $2 for update`, variant.ID, variant.ProductID).Scan(&previousStock)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ProductVariant{}, ErrNoRow
+		}
+		return entity.ProductVariant{}, fmt.Errorf("failed get product variant stock: %v", err)
+	}
+
+	err = scanProductVariant(tx.QueryRow(ctx, `
+		update product_variants set sku = $1, options = $2, price = $3, stock = $4, image_url = $5, updated_at = now()
+		where id = $6 and product_id = $7
+		returning `+productVariantColumns,
+		variant.SKU,
+		variant.Options,
+		variant.Price,
+		variant.Stock,
+		variant.ImageUrl,
+		variant.ID,
+		variant.ProductID), &variant)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ProductVariant{}, ErrNoRow
+		} else if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return entity.ProductVariant{}, ErrVariantSKUDuplicate
+		}
+		return entity.ProductVariant{}, fmt.Errorf("failed update product variant: %v", err)
+	}
+
+	if err := syncProductVariants(ctx, tx, variant.ProductID); err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	_, err = recordStockMovement(ctx, tx, actorID, entity.StockMovement{
+		ProductID: variant.ProductID,
+		VariantID: &variant.ID,
+		Type:      entity.StockMovementAdjustment,
+		Delta:     variant.Stock - previousStock,
+		Reason:    "variant updated",
+	})
+	if err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	if err := recordProductRevision(ctx, tx, variant.ProductID, actorID, entity.ProductRevisionVariant, before); err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.ProductVariant{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return variant, nil
+}
+
+// UpdateStock sets the stock of a variant, version is the expected product
+// version and is ignored when 0.
+func (v *ProductVariant) UpdateStock(ctx context.Context, actorID, productID, variantID, stock, version int) (entity.ProductVariant, error) {
+	conn, err := v.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.ProductVariant{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.ProductVariant{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	if err := checkProductVersion(ctx, tx, productID, version); err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	before, err := snapshotProduct(ctx, tx, productID)
+	if err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	var previousStock int
+	err = tx.QueryRow(ctx, `select stock from product_variants where id = $1 and product_id = $2 for update`, variantID, productID).Scan(&previousStock)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ProductVariant{}, ErrNoRow
+		}
+		return entity.ProductVariant{}, fmt.Errorf("failed get product variant stock: %v", err)
+	}
+
+	var variant entity.ProductVariant
+
+	err = scanProductVariant(tx.QueryRow(ctx, `
+		update product_variants set stock = $1, updated_at = now()
+		where id = $2 and product_id = $3
+		returning `+productVariantColumns,
+		stock, variantID, productID), &variant)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.ProductVariant{}, ErrNoRow
+		}
+		return entity.ProductVariant{}, fmt.Errorf("failed update product variant stock: %v", err)
+	}
+
+	if err := syncProductVariants(ctx, tx, productID); err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	_, err = recordStockMovement(ctx, tx, actorID, entity.StockMovement{
+		ProductID: productID,
+		VariantID: &variant.ID,
+		Type:      entity.StockMovementAdjustment,
+		Delta:     variant.Stock - previousStock,
+		Reason:    "stock set",
+	})
+	if err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	if err := recordProductRevision(ctx, tx, productID, actorID, entity.ProductRevisionVariant, before); err != nil {
+		return entity.ProductVariant{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.ProductVariant{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return variant, nil
+}
+
+func (v *ProductVariant) Delete(ctx context.Context, actorID, productID, variantID int) error {
+	conn, err := v.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	before, err := snapshotProduct(ctx, tx, productID)
+	if err != nil {
+		return err
+	}
+
+	var (
+		sku   string
+		stock int
+	)
+	err = tx.QueryRow(ctx, `delete from product_variants where id = $1 and product_id = $2 returning sku, stock`, variantID, productID).Scan(&sku, &stock)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRow
+		}
+		return fmt.Errorf("failed delete product variant: %v", err)
+	}
+
+	if err := syncProductVariants(ctx, tx, productID); err != nil {
+		return err
+	}
+
+	// syncProductVariants leaves products without variants untouched, the
+	// product stock was the stock of its last variant
+	_, err = tx.Exec(ctx, `
+		update products set stock = 0, updated_at = now()
+		where id = $1 and not exists (select 1 from product_variants where product_id = $1)
+	`, productID)
+	if err != nil {
+		return fmt.Errorf("failed reset product stock: %v", err)
+	}
+
+	// the stock of the deleted variant leaves the product
+	_, err = recordStockMovement(ctx, tx, actorID, entity.StockMovement{
+		ProductID: productID,
+		Type:      entity.StockMovementCorrection,
+		Delta:     -stock,
+		Reason:    "variant " + sku + " deleted",
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := recordProductRevision(ctx, tx, productID, actorID, entity.ProductRevisionVariant, before); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return nil
+}
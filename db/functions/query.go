@@ -0,0 +1,37 @@
+package functions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// queryBuilder collects WHERE conditions together with their bind arguments so
+// that user supplied values never end up inside the SQL string itself.
+type queryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// bind registers v as a bind argument and returns its positional placeholder.
+func (q *queryBuilder) bind(v interface{}) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// and appends a condition, it must only reference values through bind.
+func (q *queryBuilder) and(condition string) {
+	q.conditions = append(q.conditions, condition)
+}
+
+func (q *queryBuilder) where() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// escapeLike escapes the LIKE wildcards so the value is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
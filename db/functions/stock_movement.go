@@ -0,0 +1,191 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shopifyx/db/entity"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type StockMovement struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewStockMovementFn(dbPool *pgxpool.Pool) *StockMovement {
+	return &StockMovement{
+		dbPool: dbPool,
+	}
+}
+
+const stockMovementColumns = `id, product_id, variant_id, user_id, type, delta, stock_after, reason, payment_id, created_at`
+
+func scanStockMovement(row pgx.Row, movement *entity.StockMovement) error {
+	return row.Scan(
+		&movement.ID, &movement.ProductID, &movement.VariantID, &movement.UserID, &movement.Type, &movement.Delta, &movement.StockAfter, &movement.Reason, &movement.PaymentID, &movement.CreatedAt,
+	)
+}
+
+// recordStockMovement appends a movement to the ledger once the stock has been
+// changed, movements without delta are not recorded. An actorID of 0
+// attributes the movement to the product owner.
+func recordStockMovement(ctx context.Context, tx pgx.Tx, actorID int, movement entity.StockMovement) (entity.StockMovement, error) {
+	if movement.Delta == 0 {
+		return movement, nil
+	}
+
+	err := scanStockMovement(tx.QueryRow(ctx, `
+		insert into stock_movements (product_id, variant_id, user_id, type, delta, stock_after, reason, payment_id)
+		select p.id, $2, coalesce(nullif($3, 0), p.user_id), $4, $5, coalesce((select stock from product_variants where id = $2), p.stock), $6, $7
+		from products p where p.id = $1
+		returning `+stockMovementColumns,
+		movement.ProductID,
+		movement.VariantID,
+		actorID,
+		movement.Type,
+		movement.Delta,
+		movement.Reason,
+		movement.PaymentID), &movement)
+	if err != nil {
+		return entity.StockMovement{}, fmt.Errorf("failed record stock movement: %v", err)
+	}
+
+	if err := notifyLowStock(ctx, tx, movement.ProductID, movement.Delta); err != nil {
+		return entity.StockMovement{}, err
+	}
+
+	return movement, nil
+}
+
+// Adjust changes the stock of a product, or of one of its variants, by the
+// movement delta. The stock cannot go below zero. version is the expected
+// product version and is ignored when 0.
+func (m *StockMovement) Adjust(ctx context.Context, actorID int, movement entity.StockMovement, version int) (entity.StockMovement, error) {
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.StockMovement{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.StockMovement{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	var hasVariants bool
+	err = tx.QueryRow(ctx, `
+		select exists (select 1 from product_variants where product_id = products.id)
+		from products where id = $1 and deleted_at is null for update
+	`, movement.ProductID).Scan(&hasVariants)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.StockMovement{}, ErrNoRow
+		}
+		return entity.StockMovement{}, fmt.Errorf("failed get product: %v", err)
+	}
+
+	if hasVariants && movement.VariantID == nil {
+		return entity.StockMovement{}, ErrVariantRequired
+	}
+
+	if err := checkProductVersion(ctx, tx, movement.ProductID, version); err != nil {
+		return entity.StockMovement{}, err
+	}
+
+	before, err := snapshotProduct(ctx, tx, movement.ProductID)
+	if err != nil {
+		return entity.StockMovement{}, err
+	}
+
+	// the stock check constraints reject adjustments below zero
+	var stock int
+	if movement.VariantID != nil {
+		err = tx.QueryRow(ctx, `
+			update product_variants set stock = stock + $1, updated_at = now()
+			where id = $2 and product_id = $3
+			returning stock
+		`, movement.Delta, *movement.VariantID, movement.ProductID).Scan(&stock)
+	} else {
+		err = tx.QueryRow(ctx, `
+			update products set stock = stock + $1, updated_at = now()
+			where id = $2
+			returning stock
+		`, movement.Delta, movement.ProductID).Scan(&stock)
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.StockMovement{}, ErrNoRow
+		} else if strings.Contains(err.Error(), "violates check constraint") {
+			return entity.StockMovement{}, ErrNegativeStock
+		}
+		return entity.StockMovement{}, fmt.Errorf("failed adjust stock: %v", err)
+	}
+
+	if movement.VariantID != nil {
+		if err := syncProductVariants(ctx, tx, movement.ProductID); err != nil {
+			return entity.StockMovement{}, err
+		}
+	}
+
+	movement, err = recordStockMovement(ctx, tx, actorID, movement)
+	if err != nil {
+		return entity.StockMovement{}, err
+	}
+
+	if err := recordProductRevision(ctx, tx, movement.ProductID, actorID, entity.ProductRevisionStock, before); err != nil {
+		return entity.StockMovement{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.StockMovement{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return movement, nil
+}
+
+// FindByProductID returns the stock movements of a product, most recent first.
+func (m *StockMovement) FindByProductID(ctx context.Context, productID, limit, offset int) ([]entity.StockMovement, int, error) {
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var total int
+	err = conn.QueryRow(ctx, `SELECT COUNT(id) FROM stock_movements WHERE product_id = $1`, productID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get stock movements count: %v", err)
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT `+stockMovementColumns+` FROM stock_movements
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, productID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get stock movements: %v", err)
+	}
+
+	defer rows.Close()
+
+	movements := []entity.StockMovement{}
+
+	for rows.Next() {
+		movement := entity.StockMovement{}
+		err := scanStockMovement(rows, &movement)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed scan stock movements: %v", err)
+		}
+		movements = append(movements, movement)
+	}
+
+	return movements, total, nil
+}
@@ -0,0 +1,173 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shopifyx/db/entity"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type StockReservation struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewStockReservationFn(dbPool *pgxpool.Pool) *StockReservation {
+	return &StockReservation{
+		dbPool: dbPool,
+	}
+}
+
+const stockReservationColumns = `id, product_id, variant_id, user_id, qty, status, payment_id, expires_at, created_at, updated_at`
+
+func scanStockReservation(row pgx.Row, reservation *entity.StockReservation) error {
+	return row.Scan(
+		&reservation.ID, &reservation.ProductID, &reservation.VariantID, &reservation.UserID, &reservation.Qty, &reservation.Status, &reservation.PaymentID, &reservation.ExpiresAt, &reservation.CreatedAt, &reservation.UpdatedAt,
+	)
+}
+
+// heldStockSQL sums the units held by active reservations whose column
+// matches ref, ref is usually the id column of the outer query.
+func heldStockSQL(column, ref string) string {
+	return `(SELECT COALESCE(SUM(r.qty), 0) FROM stock_reservations r WHERE r.` + column + ` = ` + ref + ` AND r.status = 'active' AND r.expires_at > now())`
+}
+
+// Reserve holds the quantity of a product, or of one of its variants, for the
+// buyer until expiresAt. Only stock that is not held already can be reserved,
+// and a buyer holds at most maxQty units of a product at once.
+func (s *StockReservation) Reserve(ctx context.Context, reservation entity.StockReservation, maxQty int) (entity.StockReservation, error) {
+	conn, err := s.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.StockReservation{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return entity.StockReservation{}, fmt.Errorf("failed start transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	// the product row lock serializes reservations and purchases of the product
+	var (
+		available      int
+		hasVariants    bool
+		sellerID       int
+		isPurchaseable bool
+		held           int
+	)
+	err = tx.QueryRow(ctx, `
+		select stock - `+heldStockSQL("product_id", "products.id")+`, exists (select 1 from product_variants where product_id = products.id), user_id, is_purchaseable,
+			(select coalesce(sum(r.qty), 0) from stock_reservations r where r.product_id = products.id and r.user_id = $2 and r.status = 'active' and r.expires_at > now())
+		from products where id = $1 and deleted_at is null and status = 'published' for update
+	`, reservation.ProductID, reservation.UserID).Scan(&available, &hasVariants, &sellerID, &isPurchaseable, &held)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.StockReservation{}, ErrNoRow
+		}
+		return entity.StockReservation{}, fmt.Errorf("failed get product when reserve stock: %v", err)
+	}
+
+	if sellerID == reservation.UserID {
+		return entity.StockReservation{}, ErrReserveOwnProduct
+	}
+
+	if !isPurchaseable {
+		return entity.StockReservation{}, ErrNotPurchaseable
+	}
+
+	if held+reservation.Qty > maxQty {
+		return entity.StockReservation{}, ErrReservationLimit
+	}
+
+	if hasVariants && reservation.VariantID == nil {
+		return entity.StockReservation{}, ErrVariantRequired
+	}
+
+	if reservation.VariantID != nil {
+		err = tx.QueryRow(ctx, `
+			select stock - `+heldStockSQL("variant_id", "product_variants.id")+`
+			from product_variants where id = $1 and product_id = $2 for update
+		`, *reservation.VariantID, reservation.ProductID).Scan(&available)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return entity.StockReservation{}, ErrNoRow
+			}
+			return entity.StockReservation{}, fmt.Errorf("failed get product variant when reserve stock: %v", err)
+		}
+	}
+
+	if available < reservation.Qty {
+		return entity.StockReservation{}, ErrInsuficientQty
+	}
+
+	err = scanStockReservation(tx.QueryRow(ctx, `
+		insert into stock_reservations (product_id, variant_id, user_id, qty, expires_at)
+		values ($1, $2, $3, $4, $5)
+		returning `+stockReservationColumns,
+		reservation.ProductID,
+		reservation.VariantID,
+		reservation.UserID,
+		reservation.Qty,
+		reservation.ExpiresAt), &reservation)
+	if err != nil {
+		return entity.StockReservation{}, fmt.Errorf("failed insert stock reservation: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return entity.StockReservation{}, fmt.Errorf("failed commit transaction: %v", err)
+	}
+
+	return reservation, nil
+}
+
+// Release gives back the stock held by an active reservation of the buyer.
+func (s *StockReservation) Release(ctx context.Context, productID, reservationID, userID int) error {
+	conn, err := s.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `
+		update stock_reservations set status = 'released', updated_at = now()
+		where id = $1 and product_id = $2 and user_id = $3 and status = 'active'
+	`, reservationID, productID, userID)
+	if err != nil {
+		return fmt.Errorf("failed release stock reservation: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrReservationNotFound
+	}
+
+	return nil
+}
+
+// ReleaseExpired marks the expired reservations as released. Expired
+// reservations stop holding stock on their own, this only keeps the active
+// set small.
+func (s *StockReservation) ReleaseExpired(ctx context.Context, now time.Time) (int64, error) {
+	conn, err := s.dbPool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `
+		update stock_reservations set status = 'released', updated_at = now()
+		where status = 'active' and expires_at <= $1
+	`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed release expired stock reservations: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -100,7 +100,7 @@ func (u *User) GetUserById(ctx context.Context, userID string) (entity.User, err
 
 	var result entity.User
 
-	err = conn.QueryRow(ctx, `SELECT id, name, username FROM users WHERE id = $1`, userID).Scan(&result.Id, &result.Name, &result.Username)
+	err = conn.QueryRow(ctx, `SELECT id, name, username, is_admin FROM users WHERE id = $1`, userID).Scan(&result.Id, &result.Name, &result.Username, &result.IsAdmin)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return result, ErrNoRow
 	}
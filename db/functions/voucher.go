@@ -0,0 +1,196 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shopifyx/db/entity"
+	"shopifyx/internal/money"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Voucher struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewVoucherFn(dbPool *pgxpool.Pool) *Voucher {
+	return &Voucher{
+		dbPool: dbPool,
+	}
+}
+
+const voucherColumns = `id, code, user_id, type, value, max_discount, min_spend, currency, usage_limit, per_user_limit, used_count, starts_at, ends_at, is_active, created_at, updated_at`
+
+func scanVoucher(row pgx.Row, voucher *entity.Voucher) error {
+	return row.Scan(
+		&voucher.ID, &voucher.Code, &voucher.UserID, &voucher.Type, &voucher.Value, &voucher.MaxDiscount, &voucher.MinSpend, &voucher.Currency, &voucher.UsageLimit, &voucher.PerUserLimit, &voucher.UsedCount, &voucher.StartsAt, &voucher.EndsAt, &voucher.IsActive, &voucher.CreatedAt, &voucher.UpdatedAt,
+	)
+}
+
+// voucherDiscount is the amount a voucher takes off subtotal, percentages are
+// capped by the max discount and no discount exceeds the subtotal.
+func voucherDiscount(voucher entity.Voucher, subtotal int64) int64 {
+	discount := voucher.Value
+	if voucher.Type == entity.VoucherPercent {
+		// split to keep subtotal * value from overflowing
+		discount = subtotal/100*voucher.Value + subtotal%100*voucher.Value/100
+		if voucher.MaxDiscount > 0 && discount > voucher.MaxDiscount {
+			discount = voucher.MaxDiscount
+		}
+	}
+
+	if discount > subtotal {
+		discount = subtotal
+	}
+
+	return discount
+}
+
+// applyVoucher locks the voucher with code and checks it can be used by the
+// buyer on a subtotal spent at the seller. It returns the discount.
+func applyVoucher(ctx context.Context, tx pgx.Tx, code string, buyerID, sellerID int, subtotal money.Money, now time.Time) (entity.Voucher, money.Money, error) {
+	var voucher entity.Voucher
+
+	err := scanVoucher(tx.QueryRow(ctx, `select `+voucherColumns+` from vouchers where upper(code) = upper($1) for update`, code), &voucher)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Voucher{}, money.Money{}, ErrVoucherNotFound
+		}
+		return entity.Voucher{}, money.Money{}, fmt.Errorf("failed get voucher: %v", err)
+	}
+
+	if !voucher.IsActive || now.Before(voucher.StartsAt) || (voucher.EndsAt != nil && !now.Before(*voucher.EndsAt)) {
+		return entity.Voucher{}, money.Money{}, ErrVoucherInactive
+	}
+
+	if (voucher.UserID != nil && *voucher.UserID != sellerID) || voucher.Currency != subtotal.Currency {
+		return entity.Voucher{}, money.Money{}, ErrVoucherNotApplicable
+	}
+
+	if subtotal.Amount < voucher.MinSpend {
+		return entity.Voucher{}, money.Money{}, ErrVoucherMinSpend
+	}
+
+	if voucher.UsageLimit > 0 && voucher.UsedCount >= voucher.UsageLimit {
+		return entity.Voucher{}, money.Money{}, ErrVoucherUsedUp
+	}
+
+	if voucher.PerUserLimit > 0 {
+		var used int
+		err = tx.QueryRow(ctx, `select count(id) from voucher_redemptions where voucher_id = $1 and user_id = $2`, voucher.ID, buyerID).Scan(&used)
+		if err != nil {
+			return entity.Voucher{}, money.Money{}, fmt.Errorf("failed count voucher redemptions: %v", err)
+		}
+
+		if used >= voucher.PerUserLimit {
+			return entity.Voucher{}, money.Money{}, ErrVoucherUsedUp
+		}
+	}
+
+	return voucher, money.New(voucherDiscount(voucher, subtotal.Amount), subtotal.Currency), nil
+}
+
+// redeemVoucher counts a use of the voucher for the payment.
+func redeemVoucher(ctx context.Context, tx pgx.Tx, voucherID, buyerID, paymentID int, discount int64) error {
+	_, err := tx.Exec(ctx, `update vouchers set used_count = used_count + 1, updated_at = now() where id = $1`, voucherID)
+	if err != nil {
+		return fmt.Errorf("failed update voucher usage: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, `insert into voucher_redemptions (voucher_id, user_id, payment_id, discount) values ($1, $2, $3, $4)`, voucherID, buyerID, paymentID, discount)
+	if err != nil {
+		return fmt.Errorf("failed insert voucher redemption: %v", err)
+	}
+
+	return nil
+}
+
+// FindByOwner returns the vouchers of a seller, or the platform vouchers when
+// userID is nil, the most recent first.
+func (v *Voucher) FindByOwner(ctx context.Context, userID *int) ([]entity.Voucher, error) {
+	conn, err := v.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT `+voucherColumns+` FROM vouchers WHERE user_id IS NOT DISTINCT FROM $1 ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed get vouchers: %v", err)
+	}
+
+	defer rows.Close()
+
+	vouchers := []entity.Voucher{}
+
+	for rows.Next() {
+		voucher := entity.Voucher{}
+		err := scanVoucher(rows, &voucher)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan vouchers: %v", err)
+		}
+		vouchers = append(vouchers, voucher)
+	}
+
+	return vouchers, nil
+}
+
+func (v *Voucher) Add(ctx context.Context, voucher entity.Voucher) (entity.Voucher, error) {
+	conn, err := v.dbPool.Acquire(ctx)
+	if err != nil {
+		return entity.Voucher{}, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	err = scanVoucher(conn.QueryRow(ctx, `
+		insert into vouchers (code, user_id, type, value, max_discount, min_spend, currency, usage_limit, per_user_limit, starts_at, ends_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		returning `+voucherColumns,
+		strings.ToUpper(voucher.Code),
+		voucher.UserID,
+		voucher.Type,
+		voucher.Value,
+		voucher.MaxDiscount,
+		voucher.MinSpend,
+		voucher.Currency,
+		voucher.UsageLimit,
+		voucher.PerUserLimit,
+		voucher.StartsAt,
+		voucher.EndsAt), &voucher)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return entity.Voucher{}, ErrVoucherCodeDuplicate
+		}
+		return entity.Voucher{}, fmt.Errorf("failed insert voucher: %v", err)
+	}
+
+	return voucher, nil
+}
+
+// Deactivate stops a voucher of the owner from being used, redemptions are
+// kept.
+func (v *Voucher) Deactivate(ctx context.Context, voucherID int, userID *int) error {
+	conn, err := v.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `update vouchers set is_active = false, updated_at = now() where id = $1 and user_id is not distinct from $2`, voucherID, userID)
+	if err != nil {
+		return fmt.Errorf("failed deactivate voucher: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrVoucherNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shopifyx/db/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Wishlist struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewWishlistFn(dbPool *pgxpool.Pool) *Wishlist {
+	return &Wishlist{
+		dbPool: dbPool,
+	}
+}
+
+// FindByUserID returns the products saved by a user, most recently added
+// first. Deleted products are left out.
+func (w *Wishlist) FindByUserID(ctx context.Context, userID, limit, offset int) ([]entity.WishlistItem, int, error) {
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var total int
+	err = conn.QueryRow(ctx, `
+		SELECT COUNT(wi.product_id) FROM wishlist_items wi
+		JOIN products ON products.id = wi.product_id
+		WHERE wi.user_id = $1 AND products.deleted_at IS NULL
+	`, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get wishlist count: %v", err)
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT `+productColumns+`, wi.added_at FROM products
+		JOIN (SELECT product_id, created_at AS added_at FROM wishlist_items WHERE user_id = $1) wi ON wi.product_id = products.id
+		WHERE deleted_at IS NULL
+		ORDER BY wi.added_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed get wishlist: %v", err)
+	}
+
+	defer rows.Close()
+
+	items := []entity.WishlistItem{}
+
+	for rows.Next() {
+		item := entity.WishlistItem{}
+		err := rows.Scan(append(productFields(&item.Product), &item.AddedAt)...)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed scan wishlist: %v", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, total, nil
+}
+
+// Contains reports which of productIDs are in the wishlist of a user.
+func (w *Wishlist) Contains(ctx context.Context, userID int, productIDs []int) (map[int]bool, error) {
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT product_id FROM wishlist_items WHERE user_id = $1 AND product_id = ANY($2)`, userID, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed get wishlist items: %v", err)
+	}
+
+	defer rows.Close()
+
+	saved := map[int]bool{}
+
+	for rows.Next() {
+		var productID int
+		if err := rows.Scan(&productID); err != nil {
+			return nil, fmt.Errorf("failed scan wishlist items: %v", err)
+		}
+		saved[productID] = true
+	}
+
+	return saved, nil
+}
+
+// Add saves a published product, saving it again keeps the first date.
+func (w *Wishlist) Add(ctx context.Context, userID, productID int) error {
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var saved bool
+	err = conn.QueryRow(ctx, `
+		insert into wishlist_items (user_id, product_id)
+		select $1, id from products where id = $2 and deleted_at is null and (status = 'published' or user_id = $1)
+		on conflict (user_id, product_id) do update set user_id = excluded.user_id
+		returning true
+	`, userID, productID).Scan(&saved)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRow
+		}
+		return fmt.Errorf("failed add wishlist item: %v", err)
+	}
+
+	return nil
+}
+
+func (w *Wishlist) Remove(ctx context.Context, userID, productID int) error {
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `delete from wishlist_items where user_id = $1 and product_id = $2`, userID, productID)
+	if err != nil {
+		return fmt.Errorf("failed remove wishlist item: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRow
+	}
+
+	return nil
+}
@@ -0,0 +1,4 @@
+drop index if exists products_search_vector_idx;
+drop trigger if exists products_search_vector_trigger on products;
+drop function if exists products_search_vector_update();
+alter table products drop column if exists search_vector;
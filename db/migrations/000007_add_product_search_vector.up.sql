@@ -0,0 +1,26 @@
+/*
+add full text search vector on products, name weighs more than tags
+*/
+
+alter table products add column if not exists search_vector tsvector;
+
+create or replace function products_search_vector_update() returns trigger as $$
+begin
+    new.search_vector :=
+        setweight(to_tsvector('simple', coalesce(new.name, '')), 'A') ||
+        setweight(to_tsvector('simple', array_to_string(new.tags, ' ')), 'B');
+    return new;
+end
+$$ language plpgsql;
+
+drop trigger if exists products_search_vector_trigger on products;
+
+create trigger products_search_vector_trigger
+    before insert or update of name, tags on products
+    for each row execute function products_search_vector_update();
+
+update products set search_vector =
+    setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('simple', array_to_string(tags, ' ')), 'B');
+
+create index if not exists products_search_vector_idx on products using gin (search_vector);
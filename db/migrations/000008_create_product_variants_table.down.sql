@@ -0,0 +1,3 @@
+alter table payments drop column if exists variant_sku;
+alter table payments drop column if exists variant_id;
+drop table if exists product_variants;
@@ -0,0 +1,22 @@
+create table if not exists product_variants(
+    id bigserial primary key,
+    product_id bigint not null references products(id) on delete cascade,
+    sku varchar not null,
+    options jsonb not null default '{}'::jsonb,
+    price int not null default 0 check(price >= 0),
+    stock int not null default 0 check(stock >= 0),
+    image_url varchar not null default '',
+    purchase_count int not null default 0,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp,
+    constraint unique_variant_sku unique (product_id, sku)
+);
+
+create index if not exists product_variants_product_id_idx on product_variants (product_id);
+
+/*
+payments keep a snapshot of the bought variant
+*/
+
+alter table payments add column if not exists variant_id bigint;
+alter table payments add column if not exists variant_sku varchar;
@@ -0,0 +1,25 @@
+create table if not exists product_images(
+    id bigserial primary key,
+    product_id bigint not null references products(id) on delete cascade,
+    image_url varchar not null,
+    position int not null default 0,
+    is_cover boolean not null default false,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp
+);
+
+create index if not exists product_images_product_id_idx on product_images (product_id, position);
+
+/*
+only one cover image per product
+*/
+
+create unique index if not exists product_images_cover_idx on product_images (product_id) where is_cover;
+
+/*
+existing product images become the cover of their gallery
+*/
+
+insert into product_images (product_id, image_url, position, is_cover)
+select id, image_url, 0, true from products
+where not exists (select 1 from product_images where product_images.product_id = products.id);
@@ -0,0 +1,3 @@
+alter table users drop column if exists is_admin;
+alter table products drop column if exists category_id;
+drop table if exists categories;
@@ -0,0 +1,20 @@
+create table if not exists categories(
+    id bigserial primary key,
+    parent_id bigint references categories(id) on delete restrict,
+    "name" varchar not null,
+    slug varchar not null unique,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp
+);
+
+create index if not exists categories_parent_id_idx on categories (parent_id);
+
+alter table products add column if not exists category_id bigint references categories(id) on delete set null;
+
+create index if not exists products_category_id_idx on products (category_id);
+
+/*
+categories are managed by admins
+*/
+
+alter table users add column if not exists is_admin boolean not null default false;
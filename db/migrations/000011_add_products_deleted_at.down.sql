@@ -0,0 +1,8 @@
+delete from products where deleted_at is not null;
+
+drop index if exists products_deleted_at_idx;
+drop index if exists unique_name;
+
+alter table products add constraint unique_name unique (name, user_id);
+
+alter table products drop column if exists deleted_at;
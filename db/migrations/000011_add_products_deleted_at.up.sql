@@ -0,0 +1,15 @@
+/*
+soft delete products, deleted products stay joinable from payments
+*/
+
+alter table products add column if not exists deleted_at timestamptz;
+
+/*
+product names only need to be unique among products that are not deleted
+*/
+
+alter table products drop constraint if exists unique_name;
+
+create unique index if not exists unique_name on products (name, user_id) where deleted_at is null;
+
+create index if not exists products_deleted_at_idx on products (deleted_at) where deleted_at is not null;
@@ -0,0 +1,16 @@
+/*
+revisions are kept as evidence once their product is purged, so product_id
+does not reference products
+*/
+
+create table if not exists product_revisions(
+    id bigserial primary key,
+    product_id bigint not null,
+    user_id bigint,
+    action varchar not null,
+    before jsonb,
+    after jsonb,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists product_revisions_product_id_idx on product_revisions (product_id, created_at desc);
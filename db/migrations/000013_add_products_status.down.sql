@@ -0,0 +1,4 @@
+drop index if exists products_publish_at_idx;
+
+alter table products drop column if exists publish_at;
+alter table products drop column if exists status;
@@ -0,0 +1,11 @@
+/*
+products are only listed to other users once published, scheduled products
+are published by a background job when publish_at is reached
+*/
+
+alter table products add column if not exists status varchar not null default 'published'
+    check (status in ('draft', 'scheduled', 'published', 'archived'));
+
+alter table products add column if not exists publish_at timestamptz;
+
+create index if not exists products_publish_at_idx on products (publish_at) where status = 'scheduled';
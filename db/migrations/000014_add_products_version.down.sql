@@ -0,0 +1,4 @@
+drop trigger if exists products_version_trigger on products;
+drop function if exists products_version_update();
+
+alter table products drop column if exists version;
@@ -0,0 +1,19 @@
+/*
+version is bumped on every change of a product, clients send it back in
+If-Match to detect concurrent edits
+*/
+
+alter table products add column if not exists version bigint not null default 1;
+
+create or replace function products_version_update() returns trigger as $$
+begin
+    new.version := old.version + 1;
+    return new;
+end
+$$ language plpgsql;
+
+drop trigger if exists products_version_trigger on products;
+
+create trigger products_version_trigger
+    before update on products
+    for each row execute function products_version_update();
@@ -0,0 +1,21 @@
+/*
+a reservation holds stock for a buyer until it expires, held units are the
+active reservations that did not expire yet
+*/
+
+create table if not exists stock_reservations(
+    id bigserial primary key,
+    product_id bigint not null references products(id) on delete cascade,
+    variant_id bigint references product_variants(id) on delete cascade,
+    user_id bigint not null,
+    qty int not null check(qty > 0),
+    status varchar not null default 'active' check (status in ('active', 'consumed', 'released')),
+    payment_id bigint,
+    expires_at timestamptz not null,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp
+);
+
+create index if not exists stock_reservations_product_id_idx on stock_reservations (product_id) where status = 'active';
+create index if not exists stock_reservations_variant_id_idx on stock_reservations (variant_id) where status = 'active';
+create index if not exists stock_reservations_expires_at_idx on stock_reservations (expires_at) where status = 'active';
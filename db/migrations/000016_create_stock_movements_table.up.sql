@@ -0,0 +1,20 @@
+/*
+append-only ledger of every stock change, stock_after is the stock of the
+product, or of the variant when set, right after the movement. movements
+outlive purged products, so product_id does not reference products
+*/
+
+create table if not exists stock_movements(
+    id bigserial primary key,
+    product_id bigint not null,
+    variant_id bigint references product_variants(id) on delete set null,
+    user_id bigint,
+    type varchar not null check (type in ('sale', 'adjustment', 'restock', 'return', 'correction')),
+    delta int not null,
+    stock_after int not null,
+    reason varchar not null default '',
+    payment_id bigint,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists stock_movements_product_id_idx on stock_movements (product_id, created_at desc);
@@ -0,0 +1,5 @@
+drop table if exists notifications;
+
+drop index if exists products_low_stock_idx;
+
+alter table products drop column if exists low_stock_threshold;
@@ -0,0 +1,26 @@
+/*
+sellers are notified when the stock of a product drops to its threshold,
+a threshold of 0 disables the alert
+*/
+
+alter table products add column if not exists low_stock_threshold int not null default 0 check(low_stock_threshold >= 0);
+
+create index if not exists products_low_stock_idx on products (user_id) where low_stock_threshold > 0 and stock <= low_stock_threshold;
+
+/*
+notifications are listed in app, delivered_at is set once a notifier sent them
+*/
+
+create table if not exists notifications(
+    id bigserial primary key,
+    user_id bigint not null references users(id) on delete cascade,
+    type varchar not null,
+    product_id bigint references products(id) on delete cascade,
+    message varchar not null,
+    read_at timestamptz,
+    delivered_at timestamptz,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists notifications_user_id_idx on notifications (user_id, created_at desc);
+create index if not exists notifications_undelivered_idx on notifications (id) where delivered_at is null;
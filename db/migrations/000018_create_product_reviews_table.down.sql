@@ -0,0 +1,6 @@
+drop table if exists product_review_flags;
+drop table if exists product_reviews;
+
+drop index if exists payments_buyer_id_idx;
+
+alter table payments drop column if exists buyer_id;
@@ -0,0 +1,35 @@
+/*
+payments.user_id is the owner of the bank account, the buyer is kept apart to
+verify reviews. older payments recorded the seller as buyer, their buyer is
+unknown and stays null
+*/
+
+alter table payments add column if not exists buyer_id bigint;
+
+create index if not exists payments_buyer_id_idx on payments (buyer_id, product_id);
+
+create table if not exists product_reviews(
+    id bigserial primary key,
+    product_id bigint not null references products(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    rating smallint not null check(rating between 1 and 5),
+    body text not null default '',
+    images varchar[] not null default array[]::varchar[],
+    reply text,
+    replied_at timestamptz,
+    flag_count int not null default 0,
+    is_hidden boolean not null default false,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp,
+    constraint unique_product_review unique (product_id, user_id)
+);
+
+create index if not exists product_reviews_visible_idx on product_reviews (product_id, created_at desc) where not is_hidden;
+
+create table if not exists product_review_flags(
+    review_id bigint not null references product_reviews(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    reason varchar not null default '',
+    created_at timestamptz not null default current_timestamp,
+    primary key (review_id, user_id)
+);
@@ -0,0 +1,12 @@
+/*
+a buyer saves a product once, the product leaves the wishlist when it is purged
+*/
+
+create table if not exists wishlist_items(
+    user_id bigint not null references users(id) on delete cascade,
+    product_id bigint not null references products(id) on delete cascade,
+    created_at timestamptz not null default current_timestamp,
+    primary key (user_id, product_id)
+);
+
+create index if not exists wishlist_items_user_id_idx on wishlist_items (user_id, created_at desc);
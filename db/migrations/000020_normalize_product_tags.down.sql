@@ -0,0 +1 @@
+drop index if exists products_tags_idx;
@@ -0,0 +1,20 @@
+/*
+tags are trimmed, lower cased and deduplicated on write, existing tags are
+normalized the same way. aliases only apply from the next write
+*/
+
+update products set tags = normalized.tags
+from (
+    select p.id, coalesce(array_agg(t.tag order by t.first_position) filter (where t.tag is not null), array[]::varchar[]) as tags
+    from products p
+    left join lateral (
+        select lower(regexp_replace(btrim(tag), '\s+', ' ', 'g')) as tag, min(position) as first_position
+        from unnest(p.tags) with ordinality as u(tag, position)
+        where btrim(tag) <> ''
+        group by 1
+    ) t on true
+    group by p.id
+) normalized
+where products.id = normalized.id and products.tags is distinct from normalized.tags;
+
+create index if not exists products_tags_idx on products using gin (tags);
@@ -0,0 +1,8 @@
+alter table payments drop column if exists total_amount;
+alter table payments drop column if exists discount;
+alter table payments drop column if exists subtotal;
+alter table payments drop column if exists voucher_code;
+alter table payments drop column if exists voucher_id;
+
+drop table if exists voucher_redemptions;
+drop table if exists vouchers;
@@ -0,0 +1,52 @@
+/*
+vouchers without user_id are platform wide, otherwise they only apply to the
+products of that seller. a limit of 0 means unlimited
+*/
+
+create table if not exists vouchers(
+    id bigserial primary key,
+    code varchar not null,
+    user_id bigint references users(id) on delete cascade,
+    type varchar not null check(type in ('percent', 'fixed')),
+    value int not null check(value > 0 and (type <> 'percent' or value <= 100)),
+    max_discount int not null default 0 check(max_discount >= 0),
+    min_spend int not null default 0 check(min_spend >= 0),
+    usage_limit int not null default 0 check(usage_limit >= 0),
+    per_user_limit int not null default 0 check(per_user_limit >= 0),
+    used_count int not null default 0,
+    starts_at timestamptz not null default current_timestamp,
+    ends_at timestamptz,
+    is_active boolean not null default true,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp
+);
+
+create unique index if not exists unique_voucher_code on vouchers (upper(code));
+create index if not exists vouchers_user_id_idx on vouchers (user_id);
+
+create table if not exists voucher_redemptions(
+    id bigserial primary key,
+    voucher_id bigint not null references vouchers(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    payment_id bigint not null references payments(id) on delete cascade,
+    discount bigint not null,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists voucher_redemptions_voucher_user_idx on voucher_redemptions (voucher_id, user_id);
+
+/*
+payments keep the amounts charged at purchase time, older payments had no
+discount
+*/
+
+alter table payments add column if not exists voucher_id bigint references vouchers(id) on delete set null;
+alter table payments add column if not exists voucher_code varchar;
+alter table payments add column if not exists subtotal bigint;
+alter table payments add column if not exists discount bigint not null default 0;
+alter table payments add column if not exists total_amount bigint;
+
+update payments set subtotal = product_price::bigint * product_qty, total_amount = product_price::bigint * product_qty where subtotal is null;
+
+alter table payments alter column subtotal set not null;
+alter table payments alter column total_amount set not null;
@@ -0,0 +1,5 @@
+alter table products drop constraint if exists products_sale_window;
+
+alter table products drop column if exists sale_ends_at;
+alter table products drop column if exists sale_starts_at;
+alter table products drop column if exists sale_price;
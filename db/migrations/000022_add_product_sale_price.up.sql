@@ -0,0 +1,11 @@
+/*
+a sale price replaces the price between sale_starts_at and sale_ends_at, a
+missing start means the sale is already running. products with variants keep
+the prices of their variants
+*/
+
+alter table products add column if not exists sale_price int check(sale_price >= 0);
+alter table products add column if not exists sale_starts_at timestamptz;
+alter table products add column if not exists sale_ends_at timestamptz;
+
+alter table products add constraint products_sale_window check(sale_starts_at is null or sale_ends_at is null or sale_starts_at < sale_ends_at);
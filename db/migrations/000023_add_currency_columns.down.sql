@@ -0,0 +1,13 @@
+alter table vouchers drop column if exists currency;
+alter table vouchers alter column min_spend type int;
+alter table vouchers alter column max_discount type int;
+alter table vouchers alter column value type int;
+
+alter table payments drop column if exists currency;
+alter table payments alter column product_price type int;
+
+alter table product_variants alter column price type int;
+
+alter table products drop column if exists currency;
+alter table products alter column sale_price type int;
+alter table products alter column price type int;
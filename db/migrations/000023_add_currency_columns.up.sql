@@ -0,0 +1,20 @@
+/*
+amounts are minor units of the row currency and are widened to bigint so that
+totals do not overflow. existing amounts are rupiah. variants and sale prices
+use the currency of their product, vouchers only apply to products in their
+currency
+*/
+
+alter table products alter column price type bigint;
+alter table products alter column sale_price type bigint;
+alter table products add column if not exists currency varchar(3) not null default 'IDR' check(currency ~ '^[A-Z]{3}$');
+
+alter table product_variants alter column price type bigint;
+
+alter table payments alter column product_price type bigint;
+alter table payments add column if not exists currency varchar(3) not null default 'IDR';
+
+alter table vouchers alter column value type bigint;
+alter table vouchers alter column max_discount type bigint;
+alter table vouchers alter column min_spend type bigint;
+alter table vouchers add column if not exists currency varchar(3) not null default 'IDR' check(currency ~ '^[A-Z]{3}$');
@@ -0,0 +1,18 @@
+/*
+questions are public once posted and answered by the seller of the product,
+hidden questions are only kept for moderation
+*/
+
+create table if not exists product_questions(
+    id bigserial primary key,
+    product_id bigint not null references products(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    question text not null,
+    answer text,
+    answered_at timestamptz,
+    is_hidden boolean not null default false,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp
+);
+
+create index if not exists product_questions_visible_idx on product_questions (product_id, created_at desc) where not is_hidden;
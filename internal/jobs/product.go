@@ -0,0 +1,83 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"shopifyx/db/functions"
+	"shopifyx/internal/notifier"
+	"time"
+)
+
+// PurgeDeletedProducts permanently removes products deleted longer than
+// retention ago.
+func PurgeDeletedProducts(product *functions.Product, retention time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := product.PurgeDeleted(ctx, time.Now().Add(-retention))
+		if err != nil {
+			return err
+		}
+
+		if purged > 0 {
+			slog.Info("purged deleted products", "count", purged)
+		}
+
+		return nil
+	}
+}
+
+// PublishScheduledProducts publishes the scheduled products that reached their
+// publish time.
+func PublishScheduledProducts(product *functions.Product) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		published, err := product.PublishScheduled(ctx)
+		if err != nil {
+			return err
+		}
+
+		if published > 0 {
+			slog.Info("published scheduled products", "count", published)
+		}
+
+		return nil
+	}
+}
+
+// ReleaseExpiredReservations marks the stock reservations that expired as
+// released.
+func ReleaseExpiredReservations(reservation *functions.StockReservation) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		released, err := reservation.ReleaseExpired(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		if released > 0 {
+			slog.Info("released expired stock reservations", "count", released)
+		}
+
+		return nil
+	}
+}
+
+// DeliverNotifications hands the notifications that were not delivered yet to
+// the notifier, failed ones are retried on the next run.
+func DeliverNotifications(notification *functions.Notification, n notifier.Notifier) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		notifications, err := notification.FindUndelivered(ctx, 100)
+		if err != nil {
+			return err
+		}
+
+		for _, pending := range notifications {
+			if err := n.Notify(ctx, pending); err != nil {
+				return err
+			}
+
+			if err := notification.MarkDelivered(ctx, pending.ID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,83 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"regexp"
+)
+
+// DefaultCurrency is the currency of amounts stored before currencies existed.
+const DefaultCurrency = "IDR"
+
+var (
+	ErrOverflow         = errors.New("amount is too large")
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+	ErrUnknownRate      = errors.New("no exchange rate for currency")
+)
+
+var currencyRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// exponents are the ISO 4217 minor units of a currency, currencies missing
+// here use 2. Rupiah amounts are kept in whole rupiah.
+var exponents = map[string]int{
+	"IDR": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+}
+
+// Money is an amount in the minor units of an ISO 4217 currency, e.g. cents
+// for USD.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// IsCurrency reports whether code looks like an ISO 4217 currency code.
+func IsCurrency(code string) bool {
+	return currencyRegexp.MatchString(code)
+}
+
+// Exponent is the number of minor unit digits of currency.
+func Exponent(currency string) int {
+	if exponent, ok := exponents[currency]; ok {
+		return exponent
+	}
+
+	return 2
+}
+
+// Mul multiplies the amount by n, failing instead of overflowing.
+func (m Money) Mul(n int64) (Money, error) {
+	if n != 0 && (m.Amount > math.MaxInt64/n || m.Amount < math.MinInt64/n) {
+		return Money{}, ErrOverflow
+	}
+
+	return New(m.Amount*n, m.Currency), nil
+}
+
+// Add sums two amounts of the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+
+	if (o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount) || (o.Amount < 0 && m.Amount < math.MinInt64-o.Amount) {
+		return Money{}, ErrOverflow
+	}
+
+	return New(m.Amount+o.Amount, m.Currency), nil
+}
+
+// Sub subtracts an amount of the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+
+	return m.Add(New(-o.Amount, o.Currency))
+}
@@ -0,0 +1,104 @@
+package money
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Rates provides exchange rates, e.g. from a file or an external service.
+type Rates interface {
+	// Rate is the amount of to bought by one major unit of from.
+	Rate(ctx context.Context, from, to string) (*big.Rat, error)
+}
+
+// Convert converts m to currency with rates, rounding half away from zero to
+// the minor unit of currency.
+func Convert(ctx context.Context, rates Rates, m Money, currency string) (Money, error) {
+	if m.Currency == currency {
+		return m, nil
+	}
+
+	rate, err := rates.Rate(ctx, m.Currency, currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	amount := new(big.Rat).SetInt64(m.Amount)
+	amount.Mul(amount, rate)
+	amount.Mul(amount, new(big.Rat).SetFrac(pow10(Exponent(currency)), pow10(Exponent(m.Currency))))
+
+	// round half away from zero
+	num, denom := amount.Num(), amount.Denom()
+	quotient, remainder := new(big.Int).QuoRem(num, denom, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(denom) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(num.Sign())))
+	}
+
+	if !quotient.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+
+	return New(quotient.Int64(), currency), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// StaticRates are rates against a base currency read from a JSON file, e.g.
+// {"base": "USD", "rates": {"IDR": "16250", "SGD": "1.35"}}.
+type StaticRates struct {
+	Base  string
+	rates map[string]*big.Rat
+}
+
+func LoadStaticRates(path string) (*StaticRates, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed read rates file: %v", err)
+	}
+
+	var file struct {
+		Base  string            `json:"base"`
+		Rates map[string]string `json:"rates"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("failed parse rates file: %v", err)
+	}
+
+	if !IsCurrency(file.Base) {
+		return nil, fmt.Errorf("failed parse rates file: invalid base currency %q", file.Base)
+	}
+
+	rates := &StaticRates{
+		Base:  file.Base,
+		rates: map[string]*big.Rat{file.Base: big.NewRat(1, 1)},
+	}
+
+	for currency, value := range file.Rates {
+		rate, ok := new(big.Rat).SetString(value)
+		if !IsCurrency(currency) || !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("failed parse rates file: invalid rate %q for %q", value, currency)
+		}
+		rates.rates[currency] = rate
+	}
+
+	return rates, nil
+}
+
+func (s *StaticRates) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	fromRate, ok := s.rates[from]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownRate, from)
+	}
+
+	toRate, ok := s.rates[to]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownRate, to)
+	}
+
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
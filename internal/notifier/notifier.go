@@ -0,0 +1,21 @@
+package notifier
+
+import (
+	"context"
+	"log/slog"
+	"shopifyx/db/entity"
+)
+
+// Notifier delivers notifications outside of the app, e.g. by email or push.
+type Notifier interface {
+	Notify(ctx context.Context, notification entity.Notification) error
+}
+
+// Log writes notifications to the application log, it is used when no other
+// notifier is configured.
+type Log struct{}
+
+func (Log) Notify(ctx context.Context, notification entity.Notification) error {
+	slog.Info("notification", "user_id", notification.UserID, "type", notification.Type, "message", notification.Message)
+	return nil
+}
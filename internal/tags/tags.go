@@ -0,0 +1,60 @@
+package tags
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Aliases maps a normalized tag to the tag it is stored as, e.g. "sepatu"
+// to "shoes".
+type Aliases map[string]string
+
+// ParseAliases reads aliases written as "alias=tag" pairs separated by
+// commas, e.g. "sepatu=shoes,kaos=t-shirt".
+func ParseAliases(s string) (Aliases, error) {
+	aliases := Aliases{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		alias, tag, ok := strings.Cut(pair, "=")
+		alias, tag = Clean(alias), Clean(tag)
+		if !ok || alias == "" || tag == "" {
+			return nil, fmt.Errorf("invalid tag alias %q", pair)
+		}
+		aliases[alias] = tag
+	}
+
+	return aliases, nil
+}
+
+// Clean trims, case-folds and collapses the inner spaces of a tag.
+func Clean(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// Normalize returns the normalized tags with aliases resolved, dropping empty
+// and repeated tags while keeping the original order.
+func (a Aliases) Normalize(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = Clean(tag)
+		if alias, ok := a[tag]; ok {
+			tag = alias
+		}
+
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}